@@ -0,0 +1,148 @@
+// Package alcstest provides an in-memory stand-in for the analytics-latency-config-service client,
+// for testing code that checks table freshness without setting up gomock expectations for every
+// call.
+package alcstest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	alcsHelpers "github.com/Clever/analytics-latency-config-service/helpers"
+)
+
+// Client is an in-memory ALCS client. Seed it with per-table latencies and thresholds, and inject
+// errors or delays per table. Tables it knows nothing about have no latency and DefaultThresholds.
+type Client struct {
+	// Client is embedded so that Client implements the whole wag client interface. Calling an
+	// endpoint the fake does not implement panics.
+	alcsWagClient.Client
+
+	// DefaultThresholds are returned for tables without thresholds of their own. New sets them to
+	// no alerts at all.
+	DefaultThresholds alcs.Thresholds
+
+	lock     sync.Mutex
+	tables   map[tableKey]*table
+	requests []alcs.GetTableLatencyRequest
+}
+
+type tableKey struct {
+	database alcs.AnalyticsDatabase
+	schema   string
+	table    string
+}
+
+type table struct {
+	latency    *float64
+	thresholds *alcs.Thresholds
+	err        error
+	delay      time.Duration
+}
+
+// New returns a client that knows about no tables.
+func New() *Client {
+	return &Client{
+		DefaultThresholds: alcs.Thresholds{
+			Critical: alcsHelpers.NoLatencyAlert,
+			Major:    alcsHelpers.NoLatencyAlert,
+			Minor:    alcsHelpers.NoLatencyAlert,
+			Refresh:  alcsHelpers.NoLatencyAlert,
+		},
+		tables: map[tableKey]*table{},
+	}
+}
+
+// table returns the seeded table, adding it if needed. It must be called with the lock held.
+func (c *Client) table(database alcs.AnalyticsDatabase, schema, name string) *table {
+	key := tableKey{database: database, schema: schema, table: name}
+	if c.tables[key] == nil {
+		c.tables[key] = &table{}
+	}
+	return c.tables[key]
+}
+
+// SetLatency sets a table's latency, in hours.
+func (c *Client) SetLatency(database alcs.AnalyticsDatabase, schema, table string, latency float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.table(database, schema, table).latency = &latency
+}
+
+// SetThresholds sets a table's thresholds, e.g. alcs.Thresholds{Refresh: "10h"}.
+func (c *Client) SetThresholds(database alcs.AnalyticsDatabase, schema, table string, thresholds alcs.Thresholds) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.table(database, schema, table).thresholds = &thresholds
+}
+
+// SetError makes lookups of a table fail with err. A nil err makes them succeed again.
+func (c *Client) SetError(database alcs.AnalyticsDatabase, schema, table string, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.table(database, schema, table).err = err
+}
+
+// SetDelay makes lookups of a table take delay, or until their context is done.
+func (c *Client) SetDelay(database alcs.AnalyticsDatabase, schema, table string, delay time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.table(database, schema, table).delay = delay
+}
+
+// Requests returns the GetTableLatency requests the client has received, in order.
+func (c *Client) Requests() []alcs.GetTableLatencyRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]alcs.GetTableLatencyRequest{}, c.requests...)
+}
+
+// HealthCheck always succeeds.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// GetTableLatency returns the seeded latency and thresholds of a table.
+func (c *Client) GetTableLatency(ctx context.Context, i *alcs.GetTableLatencyRequest) (*alcs.GetTableLatencyResponse, error) {
+	var schema, name string
+	if i.Schema != nil {
+		schema = *i.Schema
+	}
+	if i.Table != nil {
+		name = *i.Table
+	}
+
+	c.lock.Lock()
+	c.requests = append(c.requests, *i)
+	seeded := table{}
+	if t, ok := c.tables[tableKey{database: i.Database, schema: schema, table: name}]; ok {
+		seeded = *t
+	}
+	thresholds := c.DefaultThresholds
+	c.lock.Unlock()
+
+	if seeded.delay > 0 {
+		timer := time.NewTimer(seeded.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if seeded.err != nil {
+		return nil, seeded.err
+	}
+	if seeded.thresholds != nil {
+		thresholds = *seeded.thresholds
+	}
+	return &alcs.GetTableLatencyResponse{
+		Database:   i.Database,
+		Schema:     &schema,
+		Table:      &name,
+		Latency:    seeded.latency,
+		Thresholds: &thresholds,
+	}, nil
+}
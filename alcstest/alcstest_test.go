@@ -0,0 +1,77 @@
+package alcstest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	alcsHelpers "github.com/Clever/analytics-latency-config-service/helpers"
+	"github.com/stretchr/testify/require"
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+
+	"github.com/Clever/analytics-util/analyticspipeline"
+)
+
+var _ alcsWagClient.Client = New()
+
+func request(database alcs.AnalyticsDatabase, schema, table string) *alcs.GetTableLatencyRequest {
+	return &alcs.GetTableLatencyRequest{Database: database, Schema: &schema, Table: &table}
+}
+
+func TestClient(t *testing.T) {
+	client := New()
+	client.SetLatency(alcs.AnalyticsDatabaseRedshiftFast, "public", "schools", 5)
+	client.SetThresholds(alcs.AnalyticsDatabaseRedshiftFast, "public", "schools", alcs.Thresholds{Refresh: "10h"})
+	client.SetError(alcs.AnalyticsDatabaseRedshiftFast, "public", "sections", errors.New("connection error"))
+	ctx := context.Background()
+
+	resp, err := client.GetTableLatency(ctx, request(alcs.AnalyticsDatabaseRedshiftFast, "public", "schools"))
+	require.NoError(t, err)
+	require.Equal(t, 5.0, *resp.Latency)
+	require.Equal(t, &alcs.Thresholds{Refresh: "10h"}, resp.Thresholds)
+	require.Equal(t, "schools", *resp.Table)
+
+	// the same table in another database is a different table
+	resp, err = client.GetTableLatency(ctx, request(alcs.AnalyticsDatabaseRedshiftProd, "public", "schools"))
+	require.NoError(t, err)
+	require.Nil(t, resp.Latency)
+	require.Equal(t, alcsHelpers.NoLatencyAlert, resp.Thresholds.Refresh)
+
+	_, err = client.GetTableLatency(ctx, request(alcs.AnalyticsDatabaseRedshiftFast, "public", "sections"))
+	require.EqualError(t, err, "connection error")
+	client.SetError(alcs.AnalyticsDatabaseRedshiftFast, "public", "sections", nil)
+	_, err = client.GetTableLatency(ctx, request(alcs.AnalyticsDatabaseRedshiftFast, "public", "sections"))
+	require.NoError(t, err)
+
+	requests := client.Requests()
+	require.Len(t, requests, 4)
+	require.Equal(t, alcs.AnalyticsDatabaseRedshiftProd, requests[1].Database)
+	require.NoError(t, client.HealthCheck(ctx))
+}
+
+func TestClientDelay(t *testing.T) {
+	client := New()
+	client.SetDelay(alcs.AnalyticsDatabaseRedshiftFast, "public", "schools", time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.GetTableLatency(ctx, request(alcs.AnalyticsDatabaseRedshiftFast, "public", "schools"))
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestClientWithFreshnessChecks(t *testing.T) {
+	client := New()
+	client.SetLatency(alcs.AnalyticsDatabaseRedshiftFast, "public", "schools", 5)
+	client.SetThresholds(alcs.AnalyticsDatabaseRedshiftFast, "public", "schools", alcs.Thresholds{Refresh: "10h"})
+	client.SetLatency(alcs.AnalyticsDatabaseRedshiftFast, "public", "sections", 15)
+	client.SetThresholds(alcs.AnalyticsDatabaseRedshiftFast, "public", "sections", alcs.Thresholds{Refresh: "10h"})
+	logger := kvlogger.NewMockCountLogger("alcstest")
+
+	require.True(t, analyticspipeline.IsTableDataFresh(logger, client, alcs.AnalyticsDatabaseRedshiftFast, "public", "schools"))
+	require.False(t, analyticspipeline.IsTableDataFresh(logger, client, alcs.AnalyticsDatabaseRedshiftFast, "public", "sections"))
+	require.False(t, analyticspipeline.IsTableDataFresh(logger, client, alcs.AnalyticsDatabaseRedshiftFast, "public", "teachers"))
+	require.Len(t, client.Requests(), 3)
+}
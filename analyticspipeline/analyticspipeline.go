@@ -13,12 +13,12 @@ import (
 
 	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
 	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
-	alcsHelpers "github.com/Clever/analytics-latency-config-service/helpers"
 	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
 )
 
 const (
 	structTagKey             = "config"
+	descriptionTagKey        = "description"
 	requiredTagKey           = "required"
 	missingValuesErrTemplate = "Missing required fields: %s"
 )
@@ -40,6 +40,25 @@ type Payload struct {
 	Current    map[string]interface{}   `json:"current"`
 	Remanining []map[string]interface{} `json:"remaining"`
 	Done       bool                     `json:"done"`
+	// Attempt is the 1-based attempt number of the current step. Runners set it when retrying a
+	// step; zero means this is the first attempt.
+	Attempt int `json:"attempt,omitempty"`
+}
+
+// WorkerOption configures optional AnalyticsWorker behavior.
+type WorkerOption func(*workerOptions)
+
+type workerOptions struct {
+	stepInfo         *StepInfo
+	freshnessChecker *FreshnessChecker
+	skipped          *bool
+}
+
+// WithStepInfo fills info with the attempt number and retry policy of the current step.
+func WithStepInfo(info *StepInfo) WorkerOption {
+	return func(o *workerOptions) {
+		o.stepInfo = info
+	}
 }
 
 // PrintPayload prints a passed in Payload
@@ -97,12 +116,16 @@ func createFlags(config reflect.Value, configFlags *flag.FlagSet, flagStringValu
 		if err != nil {
 			return err
 		}
+		usage := typedAttr.Tag.Get(descriptionTagKey)
+		if usage == "" {
+			usage = "generated field"
+		}
 		switch typedAttr.Type.Kind() {
 		case reflect.String:
-			flagStringValueMap[tagVal] = configFlags.String(tagVal, "", "generated field")
+			flagStringValueMap[tagVal] = configFlags.String(tagVal, "", usage)
 		case reflect.Bool:
 			// set the default to the value passed in
-			flagBoolValueMap[tagVal] = configFlags.Bool(tagVal, config.Field(i).Bool(), "generated field")
+			flagBoolValueMap[tagVal] = configFlags.Bool(tagVal, config.Field(i).Bool(), usage)
 		}
 	}
 	return nil
@@ -199,11 +222,16 @@ func validateRequiredFields(config reflect.Value) error {
 // Instead of containing just the structure of configStruct, JSON is expected to have a "current"
 // object that matches configStruct and an array of "remaining" payloads for future workers in the
 // workflow. Remaining payloads are returned as a printable []byte.
-func AnalyticsWorker(configStruct interface{}) (*Payload, error) {
+func AnalyticsWorker(configStruct interface{}, opts ...WorkerOption) (*Payload, error) {
 	if flag.Parsed() {
 		return nil, errFlagParsed
 	}
 
+	options := workerOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	reflectConfig := reflect.ValueOf(configStruct)
 	if reflectConfig.Kind() != reflect.Ptr {
 		return nil, errStructOnly
@@ -226,17 +254,11 @@ func AnalyticsWorker(configStruct interface{}) (*Payload, error) {
 	}
 
 	// if no flags were found and we have a value in the first arg, we try to parse JSON from it.
-	analyticsPayload := Payload{}
+	analyticsPayload := &Payload{}
 	if !flagFound && configFlags.Arg(0) != "" {
-		if err := json.NewDecoder(bytes.NewBufferString(configFlags.Arg(0))).Decode(&analyticsPayload); err != nil {
-			return nil, errInvalidJSON
-		}
-
-		if analyticsPayload.Current == nil {
-			err := attemptUnwrappedPayload(configFlags, config)
-			if err != nil {
-				return nil, err
-			}
+		analyticsPayload, err = ParsePayload([]byte(configFlags.Arg(0)))
+		if err != nil {
+			return nil, err
 		}
 
 		for i := 0; i < config.NumField(); i++ {
@@ -261,43 +283,57 @@ func AnalyticsWorker(configStruct interface{}) (*Payload, error) {
 		return nil, err
 	}
 
-	result := Payload{
-		Current:    map[string]interface{}{},
-		Remanining: []map[string]interface{}{},
+	if options.stepInfo != nil {
+		retry, err := RetryPolicyForStep(analyticsPayload.Current)
+		if err != nil {
+			return nil, err
+		}
+		options.stepInfo.Retry = retry
+		options.stepInfo.Attempt = analyticsPayload.Attempt
+		if options.stepInfo.Attempt < 1 {
+			options.stepInfo.Attempt = 1
+		}
 	}
-	if len(analyticsPayload.Remanining) > 0 {
-		result.Current = analyticsPayload.Remanining[0]
-		result.Remanining = analyticsPayload.Remanining[1:]
-	} else {
-		result.Done = true
+
+	if err := options.skipIfFresh(configStruct); err != nil {
+		return nil, err
 	}
 
-	return &result, nil
+	return analyticsPayload.Next(), nil
 }
 
-// attemptUnwrappedPayload attempts to parse a payload that is in the old format, without a
-// current and remaining attribute.
-func attemptUnwrappedPayload(configFlags *flag.FlagSet, config reflect.Value) error {
-	unwrappedPayload := map[string]interface{}{}
-	if err := json.NewDecoder(bytes.NewBufferString(configFlags.Arg(0))).Decode(&unwrappedPayload); err != nil {
-		return errInvalidJSON
+// ParsePayload parses a JSON payload. Payloads in the old format, without a current and remaining
+// attribute, are treated as the config of the current step.
+func ParsePayload(raw []byte) (*Payload, error) {
+	payload := Payload{}
+	if err := json.NewDecoder(bytes.NewBuffer(raw)).Decode(&payload); err != nil {
+		return nil, errInvalidJSON
 	}
-	for i := 0; i < config.NumField(); i++ {
-		valueField := config.Field(i)
-		tagVal, _, err := parseTagKey(config.Type().Field(i).Tag.Get(structTagKey))
-		if err != nil {
-			return err
-		} else if _, ok := unwrappedPayload[tagVal]; ok {
-			typedAttr := config.Type().Field(i)
-			switch typedAttr.Type.Kind() {
-			case reflect.String:
-				valueField.SetString(unwrappedPayload[tagVal].(string))
-			case reflect.Bool:
-				valueField.SetBool(unwrappedPayload[tagVal].(bool))
-			}
+
+	if payload.Current == nil {
+		unwrappedPayload := map[string]interface{}{}
+		if err := json.NewDecoder(bytes.NewBuffer(raw)).Decode(&unwrappedPayload); err != nil {
+			return nil, errInvalidJSON
 		}
+		payload.Current = unwrappedPayload
 	}
-	return nil
+	return &payload, nil
+}
+
+// Next returns the payload for the step after the current one.
+func (p *Payload) Next() *Payload {
+	result := Payload{
+		Current:    map[string]interface{}{},
+		Remanining: []map[string]interface{}{},
+	}
+	if len(p.Remanining) > 0 {
+		result.Current = p.Remanining[0]
+		result.Remanining = p.Remanining[1:]
+	} else {
+		result.Done = true
+	}
+
+	return &result
 }
 
 // IsTableDataFresh checks with ALCS to see if the table data is fresh.
@@ -309,48 +345,6 @@ func IsTableDataFresh(
 	schema string,
 	table string,
 ) bool {
-	logPayload := kvlogger.M{
-		"database": database,
-		"schema":   schema,
-		"table":    table,
-	}
-
-	latency, err := alcsClient.GetTableLatency(context.TODO(), &alcs.GetTableLatencyRequest{
-		Database: database,
-		Schema:   &schema,
-		Table:    &table,
-	})
-
-	if err != nil {
-		// If we get an error, assume latency check fails, and run the job.
-		logPayload["error"] = err
-		logger.InfoD("latency-debounce-error", logPayload)
-		return false
-	} else if latency.Latency == nil {
-		// No latency returned means that we should run the job.
-		logPayload["threshold"] = latency.Thresholds.Refresh
-		logPayload["latency"] = "not found"
-		logger.InfoD("latency-debounce-empty", logPayload)
-		return false
-	} else if latency.Thresholds.Refresh == alcsHelpers.NoLatencyAlert {
-		// No refresh latency specified means that we should run the job.
-		logPayload["threshold"] = "none"
-		logPayload["latency"] = *latency.Latency
-		logger.InfoD("latency-debounce-unset", logPayload)
-		return false
-	} else {
-		crossed, threshold, err := alcsHelpers.CheckThresholdCrossed(*latency.Latency, latency.Thresholds, alcs.ThresholdTierRefresh)
-		logPayload["threshold"] = threshold
-		logPayload["latency"] = *latency.Latency
-
-		// Again, if we had an error, fall back to running the job
-		if err == nil && !crossed {
-			// We don't need to run the task. Bail out early.
-			logger.InfoD("latency-debounce-fresh", logPayload)
-			return true
-		}
-		// Otherwise, run the job
-		logger.InfoD("latency-debounce-stale", logPayload)
-		return false
-	}
+	checker := &FreshnessChecker{Logger: logger, Client: alcsClient}
+	return checker.IsTableDataFresh(context.TODO(), database, schema, table)
 }
@@ -0,0 +1,121 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+)
+
+// DefaultBatchConcurrency is the number of ALCS calls CheckTables makes at once when it is not
+// given a limit.
+const DefaultBatchConcurrency = 8
+
+// Table identifies a table tracked by ALCS.
+type Table struct {
+	Database alcs.AnalyticsDatabase
+	Schema   string
+	Name     string
+}
+
+// String returns the table as database:schema.name.
+func (t Table) String() string {
+	return fmt.Sprintf("%s:%s.%s", t.Database, t.Schema, t.Name)
+}
+
+// CheckTables checks the freshness of every table, making at most concurrency ALCS calls at once. A
+// concurrency below 1 means DefaultBatchConcurrency. Instead of logging every table, it logs a
+// single latency-debounce-batch event summarizing the results.
+func (c *FreshnessChecker) CheckTables(ctx context.Context, tables []Table, concurrency int) map[Table]FreshnessResult {
+	results := c.checkAll(ctx, tables, concurrency, c.decide)
+	c.Logger.InfoD("latency-debounce-batch", batchSummary(results))
+	return results
+}
+
+// checkAll checks every table concurrently with check, without logging.
+func (c *FreshnessChecker) checkAll(
+	ctx context.Context,
+	tables []Table,
+	concurrency int,
+	check func(context.Context, Table) FreshnessResult,
+) map[Table]FreshnessResult {
+	if concurrency < 1 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	var (
+		results = make(map[Table]FreshnessResult, len(tables))
+		seen    = make(map[Table]bool, len(tables))
+		lock    sync.Mutex
+		sem     = make(chan struct{}, concurrency)
+		wg      sync.WaitGroup
+	)
+	for _, table := range tables {
+		// duplicates are only checked once
+		if seen[table] {
+			continue
+		}
+		seen[table] = true
+		wg.Add(1)
+		go func(table Table) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			result := check(ctx, table)
+			lock.Lock()
+			defer lock.Unlock()
+			results[table] = result
+		}(table)
+	}
+	wg.Wait()
+	return results
+}
+
+// decide checks a table the way CheckTableFreshness does, applying the checker's Policy and
+// emitting its Metrics, without logging.
+func (c *FreshnessChecker) decide(ctx context.Context, table Table) FreshnessResult {
+	result, _, _ := c.check(ctx, table)
+	return result
+}
+
+// observe checks a table without applying the checker's Policy or emitting its Metrics.
+func (c *FreshnessChecker) observe(ctx context.Context, table Table) FreshnessResult {
+	result, _, _ := c.evaluate(ctx, table)
+	return result
+}
+
+// batchSummary builds the log payload for a batch of results: how many tables were checked, how
+// many of them were fresh, a count per reason and the tables that need to run.
+func batchSummary(results map[Table]FreshnessResult) kvlogger.M {
+	var (
+		fresh        int
+		failedClosed int
+		reasons      = map[string]interface{}{}
+		notFresh     = []string{}
+	)
+	for table, result := range results {
+		count, _ := reasons[string(result.Reason)].(int)
+		reasons[string(result.Reason)] = count + 1
+		if !result.Fresh {
+			notFresh = append(notFresh, table.String())
+			continue
+		}
+		fresh++
+		if result.Reason != ReasonFresh {
+			failedClosed++
+		}
+	}
+	sort.Strings(notFresh)
+
+	return kvlogger.M{
+		"tables":        len(results),
+		"fresh":         fresh,
+		"not_fresh":     len(notFresh),
+		"failed_closed": failedClosed,
+		"reasons":       reasons,
+		"run_tables":    notFresh,
+	}
+}
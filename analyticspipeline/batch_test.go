@@ -0,0 +1,136 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTables(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockALCS := alcsWagClient.NewMockClient(mockCtrl)
+
+	latencies := map[string]*float64{"fresh": floatPtr(5), "stale": floatPtr(15), "empty": nil}
+	var (
+		lock     sync.Mutex
+		inFlight int
+		maxSeen  int
+	)
+	mockALCS.EXPECT().GetTableLatency(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, req *alcs.GetTableLatencyRequest) (*alcs.GetTableLatencyResponse, error) {
+			lock.Lock()
+			inFlight++
+			if inFlight > maxSeen {
+				maxSeen = inFlight
+			}
+			lock.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			lock.Lock()
+			inFlight--
+			lock.Unlock()
+
+			latency, ok := latencies[*req.Schema]
+			if !ok {
+				return nil, fmt.Errorf("connection error")
+			}
+			return &alcs.GetTableLatencyResponse{Latency: latency, Thresholds: &alcs.Thresholds{Refresh: "10h"}}, nil
+		}).Times(8)
+
+	var tables []Table
+	for _, schema := range []string{"fresh", "stale", "empty", "error"} {
+		for _, name := range []string{"a", "b"} {
+			tables = append(tables, Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: schema, Name: name})
+		}
+	}
+	// duplicates are only checked once
+	tables = append(tables, tables[0])
+
+	logger := newRecordingLogger()
+	checker := &FreshnessChecker{Logger: logger, Client: mockALCS}
+	results := checker.CheckTables(context.Background(), tables, 2)
+
+	require.Len(t, results, 8)
+	require.True(t, maxSeen <= 2, "at most 2 calls should run at once, saw %d", maxSeen)
+	for table, result := range results {
+		switch table.Schema {
+		case "fresh":
+			require.Equal(t, ReasonFresh, result.Reason)
+			require.True(t, result.Fresh)
+		case "stale":
+			require.Equal(t, ReasonStale, result.Reason)
+		case "empty":
+			require.Equal(t, ReasonNoLatency, result.Reason)
+		case "error":
+			require.Equal(t, ReasonError, result.Reason)
+		}
+	}
+
+	require.Equal(t, []string{"latency-debounce-batch"}, logger.Titles())
+	summary := logger.data[0]
+	require.Equal(t, 8, summary["tables"])
+	require.Equal(t, 2, summary["fresh"])
+	require.Equal(t, 6, summary["not_fresh"])
+	require.Equal(t, 0, summary["failed_closed"])
+	require.Equal(t, map[string]interface{}{"fresh": 2, "stale": 2, "no-latency": 2, "error": 2}, summary["reasons"])
+	var runTables []string
+	for _, table := range tables[2:8] {
+		runTables = append(runTables, table.String())
+	}
+	require.ElementsMatch(t, runTables, summary["run_tables"])
+}
+
+func TestCheckTablesFailClosed(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockALCS := alcsWagClient.NewMockClient(mockCtrl)
+	mockALCS.EXPECT().GetTableLatency(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("connection error")).Times(3)
+
+	logger := newRecordingLogger()
+	checker := &FreshnessChecker{Logger: logger, Client: mockALCS, Policy: &FailurePolicy{Mode: FailClosed}}
+	results := checker.CheckTables(context.Background(), []Table{
+		{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "s", Name: "a"},
+		{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "s", Name: "b"},
+		{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "s", Name: "c"},
+	}, 0)
+
+	for _, result := range results {
+		require.True(t, result.Fresh)
+	}
+	require.Equal(t, 3, logger.data[0]["failed_closed"])
+	require.Equal(t, []string{}, logger.data[0]["run_tables"])
+}
+
+func TestCheckTablesFailClosedAfterNIgnoresConfig(t *testing.T) {
+	source := mapSource{}
+	var tables []Table
+	for i := 0; i < 10; i++ {
+		table := Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "s", Name: fmt.Sprintf("unset_%d", i)}
+		source[table] = &TableLatency{Latency: floatPtr(5)}
+		tables = append(tables, table)
+		table = Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "s", Name: fmt.Sprintf("fresh_%d", i)}
+		source[table] = &TableLatency{Latency: floatPtr(5), Thresholds: &alcs.Thresholds{Refresh: "10h"}}
+		tables = append(tables, table)
+	}
+
+	logger := newRecordingLogger()
+	checker := &FreshnessChecker{Logger: logger, Source: source, Policy: &FailurePolicy{Mode: FailClosedAfterN, N: 1}}
+	results := checker.CheckTables(context.Background(), tables, 0)
+
+	for table, result := range results {
+		if result.Reason == ReasonNoThreshold {
+			require.False(t, result.Fresh, "%s has no threshold, which is not an outage", table)
+		} else {
+			require.Equal(t, ReasonFresh, result.Reason)
+			require.True(t, result.Fresh)
+		}
+	}
+	require.Equal(t, 0, logger.data[0]["failed_closed"])
+}
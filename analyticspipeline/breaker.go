@@ -0,0 +1,150 @@
+package analyticspipeline
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+)
+
+// ErrBreakerOpen is returned instead of calling a latency source while its circuit breaker is open.
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+// Circuit breaker states
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every call until the cooldown passes.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial call through, to decide whether to close or reopen.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker stops calls to a latency source after it fails FailureThreshold times in a row,
+// so that an ALCS outage costs each check one fast error instead of a round of retries. After
+// Cooldown it lets a trial call through, and closes again if the call succeeds. Share one breaker
+// across the checks in a process. State transitions are logged as latency-circuit-breaker.
+type CircuitBreaker struct {
+	// Logger logs state transitions. A nil Logger logs nothing.
+	Logger kvlogger.KayveeLogger
+	// FailureThreshold is the number of failures in a row that opens the breaker. It defaults to 5.
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open. It defaults to 30 seconds.
+	Cooldown time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	lock     sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+// State returns the breaker's current state.
+func (b *CircuitBreaker) State() BreakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+// allow returns ErrBreakerOpen if a call should not be made. Once the cooldown has passed, it lets
+// a single trial call through.
+func (b *CircuitBreaker) allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown() {
+			return ErrBreakerOpen
+		}
+		b.transition(BreakerHalfOpen)
+		return nil
+	case BreakerHalfOpen:
+		// a trial call is already in flight
+		return ErrBreakerOpen
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of a call it allowed.
+func (b *CircuitBreaker) record(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err == nil {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.transition(BreakerClosed)
+		}
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.failureThreshold() {
+		b.openedAt = b.now()
+		if b.state != BreakerOpen {
+			b.transition(BreakerOpen)
+		}
+	}
+}
+
+// abandon forgets a call it allowed without an outcome. If it was the trial call, the breaker
+// reopens so that the next call becomes the trial.
+func (b *CircuitBreaker) abandon() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.transition(BreakerOpen)
+	}
+}
+
+// transition changes state and logs it. It must be called with the lock held.
+func (b *CircuitBreaker) transition(to BreakerState) {
+	from := b.state
+	b.state = to
+	if b.Logger == nil {
+		return
+	}
+	b.Logger.InfoD("latency-circuit-breaker", kvlogger.M{
+		"from":     from.String(),
+		"to":       to.String(),
+		"failures": b.failures,
+	})
+}
+
+func (b *CircuitBreaker) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
+
+func (b *CircuitBreaker) failureThreshold() int {
+	if b.FailureThreshold > 0 {
+		return b.FailureThreshold
+	}
+	return 5
+}
+
+func (b *CircuitBreaker) cooldown() time.Duration {
+	if b.Cooldown > 0 {
+		return b.Cooldown
+	}
+	return 30 * time.Second
+}
@@ -0,0 +1,76 @@
+package analyticspipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	logger := newRecordingLogger()
+	breaker := &CircuitBreaker{Logger: logger, FailureThreshold: 2, Cooldown: time.Minute, Now: func() time.Time { return now }}
+	failure := errTransient
+
+	// failures below the threshold, or interrupted by a success, keep it closed
+	require.NoError(t, breaker.allow())
+	breaker.record(failure)
+	require.NoError(t, breaker.allow())
+	breaker.record(nil)
+	require.NoError(t, breaker.allow())
+	breaker.record(failure)
+	require.Equal(t, BreakerClosed, breaker.State())
+
+	// failures in a row open it
+	require.NoError(t, breaker.allow())
+	breaker.record(failure)
+	require.Equal(t, BreakerOpen, breaker.State())
+	require.Equal(t, ErrBreakerOpen, breaker.allow())
+
+	// after the cooldown a single trial call goes through, and a failure reopens it
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.allow())
+	require.Equal(t, BreakerHalfOpen, breaker.State())
+	require.Equal(t, ErrBreakerOpen, breaker.allow())
+	breaker.record(failure)
+	require.Equal(t, BreakerOpen, breaker.State())
+	require.Equal(t, ErrBreakerOpen, breaker.allow())
+
+	// an abandoned trial lets the next call try
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.allow())
+	breaker.abandon()
+	require.NoError(t, breaker.allow())
+
+	// a successful trial closes it
+	breaker.record(nil)
+	require.Equal(t, BreakerClosed, breaker.State())
+
+	require.Equal(t, []string{
+		"latency-circuit-breaker", // closed -> open
+		"latency-circuit-breaker", // open -> half-open
+		"latency-circuit-breaker", // half-open -> open
+		"latency-circuit-breaker", // open -> half-open
+		"latency-circuit-breaker", // half-open -> open (abandoned)
+		"latency-circuit-breaker", // open -> half-open
+		"latency-circuit-breaker", // half-open -> closed
+	}, logger.Titles())
+	require.Equal(t, "closed", logger.data[0]["from"])
+	require.Equal(t, "open", logger.data[0]["to"])
+	require.Equal(t, 2, logger.data[0]["failures"])
+	require.Equal(t, "half-open", logger.data[6]["from"])
+	require.Equal(t, "closed", logger.data[6]["to"])
+}
+
+func TestCircuitBreakerDefaults(t *testing.T) {
+	breaker := &CircuitBreaker{}
+	for i := 0; i < 4; i++ {
+		breaker.record(errTransient)
+	}
+	require.Equal(t, BreakerClosed, breaker.State())
+	breaker.record(errTransient)
+	require.Equal(t, BreakerOpen, breaker.State())
+	require.Equal(t, ErrBreakerOpen, breaker.allow())
+	require.Equal(t, "half-open", BreakerHalfOpen.String())
+}
@@ -0,0 +1,150 @@
+package analyticspipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+)
+
+// CachedSource caches the latencies another LatencySource looks up, so that the many invocations of
+// a fan-out step don't all ask ALCS about the same table. Latencies are cached in memory and, if Path
+// is set, in a JSON file that worker subprocesses can share.
+//
+// A cached latency is aged by the time since it was cached, so a table that was fresh when cached
+// can become stale before the entry expires. Errors are never cached, and a cache file that cannot
+// be read or written is ignored.
+type CachedSource struct {
+	Source LatencySource
+	// TTL is how long a lookup is cached for.
+	TTL time.Duration
+	// Path is a file to share the cache through. Empty means the cache is only kept in memory.
+	Path string
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]cachedLatency
+}
+
+// cachedLatency is a cache entry, as kept in memory and in the cache file.
+type cachedLatency struct {
+	Latency    *float64         `json:"latency,omitempty"`
+	Thresholds *alcs.Thresholds `json:"thresholds,omitempty"`
+	CachedAt   time.Time        `json:"cached_at"`
+}
+
+// TableLatency returns the cached latency of a table, looking it up in Source if it is not cached
+// or the entry has expired.
+func (s *CachedSource) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	now := s.now()
+	if entry, ok := s.get(table.String(), now); ok {
+		return entry.latency(now), nil
+	}
+
+	latency, err := s.Source.TableLatency(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if latency == nil {
+		latency = &TableLatency{}
+	}
+	s.put(table.String(), cachedLatency{Latency: latency.Latency, Thresholds: latency.Thresholds, CachedAt: now})
+	return latency, nil
+}
+
+func (s *CachedSource) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// get returns the unexpired entry for key, checking memory first and then the cache file.
+func (s *CachedSource) get(key string, now time.Time) (cachedLatency, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if entry, ok := s.entries[key]; ok && now.Sub(entry.CachedAt) < s.TTL {
+		return entry, true
+	}
+	if s.Path == "" {
+		return cachedLatency{}, false
+	}
+	entry, ok := s.readFile()[key]
+	if !ok || now.Sub(entry.CachedAt) >= s.TTL {
+		return cachedLatency{}, false
+	}
+	s.remember(key, entry)
+	return entry, true
+}
+
+// put caches an entry in memory and in the cache file.
+func (s *CachedSource) put(key string, entry cachedLatency) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.remember(key, entry)
+	if s.Path == "" {
+		return
+	}
+	// other processes may have cached tables since we last read the file
+	entries := s.readFile()
+	entries[key] = entry
+	s.writeFile(entries)
+}
+
+func (s *CachedSource) remember(key string, entry cachedLatency) {
+	if s.entries == nil {
+		s.entries = map[string]cachedLatency{}
+	}
+	s.entries[key] = entry
+}
+
+// readFile returns the entries in the cache file, or none if it cannot be read.
+func (s *CachedSource) readFile() map[string]cachedLatency {
+	entries := map[string]cachedLatency{}
+	raw, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return entries
+	}
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return map[string]cachedLatency{}
+	}
+	return entries
+}
+
+// writeFile replaces the cache file with entries. It writes to a temporary file first so that
+// other processes never read a partial cache.
+func (s *CachedSource) writeFile(entries map[string]cachedLatency) {
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path))
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(raw)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+	os.Rename(tmp.Name(), s.Path)
+}
+
+// latency returns the cached latency, aged by the time since it was cached.
+func (e cachedLatency) latency(now time.Time) *TableLatency {
+	latency := &TableLatency{Thresholds: e.Thresholds, CachedAt: e.CachedAt}
+	if e.Latency != nil {
+		aged := *e.Latency + now.Sub(e.CachedAt).Hours()
+		latency.Latency = &aged
+	}
+	return latency
+}
@@ -0,0 +1,126 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSource counts lookups, and returns a latency that grows with each one.
+type countingSource struct {
+	calls int
+	err   error
+}
+
+func (s *countingSource) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	latency := float64(s.calls)
+	return &TableLatency{Latency: &latency, Thresholds: &alcs.Thresholds{Refresh: "10h"}}, nil
+}
+
+func TestCachedSource(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	inner := &countingSource{}
+	source := &CachedSource{Source: inner, TTL: time.Hour, Now: func() time.Time { return now }}
+	schools := Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "schools"}
+	sections := Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "sections"}
+
+	latency, err := source.TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, *latency.Latency)
+	require.True(t, latency.CachedAt.IsZero())
+
+	// a cached latency is aged by the time since it was cached
+	cachedAt := now
+	now = now.Add(30 * time.Minute)
+	latency, err = source.TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+	require.Equal(t, 1.5, *latency.Latency)
+	require.Equal(t, cachedAt, latency.CachedAt)
+	require.Equal(t, 1, inner.calls)
+
+	// tables are cached separately
+	_, err = source.TableLatency(context.Background(), sections)
+	require.NoError(t, err)
+	require.Equal(t, 2, inner.calls)
+
+	// expired entries are looked up again
+	now = now.Add(time.Hour)
+	latency, err = source.TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+	require.Equal(t, 3.0, *latency.Latency)
+	require.True(t, latency.CachedAt.IsZero())
+
+	// errors are not cached
+	failing := &CachedSource{Source: &countingSource{err: fmt.Errorf("connection error")}, TTL: time.Hour}
+	for i := 0; i < 2; i++ {
+		_, err = failing.TableLatency(context.Background(), schools)
+		require.EqualError(t, err, "connection error")
+	}
+	require.Equal(t, 2, failing.Source.(*countingSource).calls)
+
+	// a nil latency is cached as an empty one
+	empty := &CachedSource{Source: staticSource{}, TTL: time.Hour, Now: func() time.Time { return now }}
+	latency, err = empty.TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+	require.Equal(t, &TableLatency{}, latency)
+	latency, err = empty.TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+	require.Nil(t, latency.Latency)
+	require.Equal(t, now, latency.CachedAt)
+}
+
+func TestCachedSourceFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "analytics-util-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "latency-cache.json")
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	schools := Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "schools"}
+
+	first := &countingSource{}
+	_, err = (&CachedSource{Source: first, TTL: time.Hour, Path: path, Now: clock}).TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+
+	// another process shares the cache through the file
+	second := &countingSource{}
+	latency, err := (&CachedSource{Source: second, TTL: time.Hour, Path: path, Now: clock}).TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+	require.Equal(t, 0, second.calls)
+	require.Equal(t, 1.0, *latency.Latency)
+	require.Equal(t, now, latency.CachedAt.UTC())
+
+	// a corrupt cache file is ignored
+	require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0644))
+	third := &countingSource{}
+	_, err = (&CachedSource{Source: third, TTL: time.Hour, Path: path, Now: clock}).TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+	require.Equal(t, 1, third.calls)
+}
+
+func TestFreshnessCheckerLogsCacheHits(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	logger := newRecordingLogger()
+	checker := &FreshnessChecker{
+		Logger: logger,
+		Source: &CachedSource{Source: &countingSource{}, TTL: time.Hour, Now: func() time.Time { return now }},
+	}
+	for i := 0; i < 2; i++ {
+		checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRedshiftFast, "public", "schools")
+	}
+	require.NotContains(t, logger.data[0], "cache_hit")
+	require.Equal(t, true, logger.data[1]["cache_hit"])
+	require.Equal(t, "2020-06-01T12:00:00Z", logger.data[1]["cached_at"])
+}
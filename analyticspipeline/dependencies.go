@@ -0,0 +1,209 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DependencyGraph records which upstream tables each table is built from.
+type DependencyGraph struct {
+	upstreams map[Table][]Table
+}
+
+// NewDependencyGraph returns an empty graph.
+func NewDependencyGraph() *DependencyGraph {
+	return &DependencyGraph{upstreams: map[Table][]Table{}}
+}
+
+// Add declares that table is built from upstreams.
+func (g *DependencyGraph) Add(table Table, upstreams ...Table) {
+	for _, upstream := range upstreams {
+		if !containsTable(g.upstreams[table], upstream) {
+			g.upstreams[table] = append(g.upstreams[table], upstream)
+		}
+	}
+}
+
+// Upstreams returns the tables table is built from, in the order they were declared. A nil graph
+// has no upstreams.
+func (g *DependencyGraph) Upstreams(table Table) []Table {
+	if g == nil {
+		return []Table{}
+	}
+	return append([]Table{}, g.upstreams[table]...)
+}
+
+func containsTable(tables []Table, table Table) bool {
+	for _, t := range tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlTable is a table in a dependency file.
+type yamlTable struct {
+	Database  alcs.AnalyticsDatabase `yaml:"database"`
+	Schema    string                 `yaml:"schema"`
+	Table     string                 `yaml:"table"`
+	Upstreams []yamlTable            `yaml:"upstreams"`
+}
+
+// LoadDependencyGraph reads a graph from a YAML file. See ParseDependencyGraph for the format.
+func LoadDependencyGraph(path string) (*DependencyGraph, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := ParseDependencyGraph(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return graph, nil
+}
+
+// ParseDependencyGraph parses a graph from YAML, as a list of tables and their upstreams. An
+// upstream without a database is in the same database as its table:
+//
+//	- database: RedshiftFast
+//	  schema: public
+//	  table: school_summary
+//	  upstreams:
+//	    - {schema: public, table: schools}
+//	    - {database: RdsInternal, schema: audit, table: loads}
+func ParseDependencyGraph(raw []byte) (*DependencyGraph, error) {
+	var tables []yamlTable
+	if err := yaml.UnmarshalStrict(raw, &tables); err != nil {
+		return nil, err
+	}
+
+	graph := NewDependencyGraph()
+	for i, t := range tables {
+		if t.Database == "" || t.Schema == "" || t.Table == "" {
+			return nil, fmt.Errorf("table %d: database, schema and table are required", i)
+		}
+		table := Table{Database: t.Database, Schema: t.Schema, Name: t.Table}
+		for j, u := range t.Upstreams {
+			if u.Schema == "" || u.Table == "" {
+				return nil, fmt.Errorf("%s: upstream %d: schema and table are required", table, j)
+			}
+			if len(u.Upstreams) > 0 {
+				return nil, fmt.Errorf("%s: upstream %d: declare the upstreams of %s.%s as its own table", table, j, u.Schema, u.Table)
+			}
+			upstream := Table{Database: u.Database, Schema: u.Schema, Name: u.Table}
+			if upstream.Database == "" {
+				upstream.Database = table.Database
+			}
+			graph.Add(table, upstream)
+		}
+	}
+	return graph, nil
+}
+
+// DependencyReason explains whether a table is worth rebuilding given its upstreams.
+type DependencyReason string
+
+// Dependency check outcomes
+const (
+	// DependencyFresh means the table is fresh.
+	DependencyFresh DependencyReason = "fresh"
+	// DependencyUpstreamChanged means the table is stale and an upstream was loaded after it.
+	DependencyUpstreamChanged DependencyReason = "stale-upstream-changed"
+	// DependencyUpstreamUnchanged means the table is stale, but none of its upstreams were loaded
+	// after it, so rebuilding it would not change it.
+	DependencyUpstreamUnchanged DependencyReason = "stale-upstream-unchanged"
+	// DependencyUnknown means the table is stale and its upstreams could not be compared with it:
+	// a latency is unknown or no upstreams are declared.
+	DependencyUnknown DependencyReason = "unknown"
+)
+
+// DependencyResult is the outcome of checking a table against its upstreams.
+type DependencyResult struct {
+	Reason DependencyReason
+	// Target is the freshness of the table itself.
+	Target FreshnessResult
+	// Upstreams are the latencies of the table's upstreams, in hours. A nil latency is unknown.
+	Upstreams map[Table]*float64
+	// Changed are the upstreams loaded after the table, sorted.
+	Changed []Table
+	// Err is the first error looking up an upstream's latency.
+	Err error
+}
+
+// ShouldRebuild reports whether the table is worth rebuilding: it is stale and its upstreams have,
+// or may have, new data.
+func (r DependencyResult) ShouldRebuild() bool {
+	return r.Reason == DependencyUpstreamChanged || r.Reason == DependencyUnknown
+}
+
+// CheckDependencies checks whether a table is fresh and, if it is not, whether any of its upstreams
+// in graph were loaded after it, i.e. have a lower latency. A table without upstreams, e.g. with a
+// nil graph, is DependencyUnknown when stale. The outcome is logged as latency-dependencies.
+func (c *FreshnessChecker) CheckDependencies(ctx context.Context, graph *DependencyGraph, table Table) DependencyResult {
+	target, _, _ := c.check(ctx, table)
+	result := DependencyResult{Target: target, Upstreams: map[Table]*float64{}, Changed: []Table{}}
+	upstreams := graph.Upstreams(table)
+
+	switch {
+	case target.Fresh:
+		result.Reason = DependencyFresh
+	case target.Latency == nil || len(upstreams) == 0:
+		result.Reason = DependencyUnknown
+	default:
+		result.Reason = DependencyUpstreamUnchanged
+		for _, upstream := range upstreams {
+			latency, err := c.getTableLatency(ctx, upstream)
+			if err != nil {
+				if result.Err == nil {
+					result.Err = fmt.Errorf("%s: %s", upstream, err)
+				}
+				result.Upstreams[upstream] = nil
+				continue
+			}
+			result.Upstreams[upstream] = latency.Latency
+			if latency.Latency != nil && *latency.Latency < *target.Latency {
+				result.Changed = append(result.Changed, upstream)
+			}
+		}
+		sort.Slice(result.Changed, func(i, j int) bool { return result.Changed[i].String() < result.Changed[j].String() })
+
+		if len(result.Changed) > 0 {
+			result.Reason = DependencyUpstreamChanged
+		} else {
+			for _, latency := range result.Upstreams {
+				if latency == nil {
+					result.Reason = DependencyUnknown
+				}
+			}
+		}
+	}
+
+	changed := make([]string, len(result.Changed))
+	for i, upstream := range result.Changed {
+		changed[i] = upstream.String()
+	}
+	logPayload := kvlogger.M{
+		"database":  table.Database,
+		"schema":    table.Schema,
+		"table":     table.Name,
+		"reason":    string(result.Reason),
+		"freshness": string(target.Reason),
+		"upstreams": len(upstreams),
+		"changed":   changed,
+	}
+	if target.Latency != nil {
+		logPayload["latency"] = *target.Latency
+	}
+	if result.Err != nil {
+		logPayload["error"] = result.Err
+	}
+	c.Logger.InfoD("latency-dependencies", logPayload)
+	return result
+}
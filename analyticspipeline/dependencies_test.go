@@ -0,0 +1,161 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+// mapSource looks tables up in a map. Tables missing from it are an error.
+type mapSource map[Table]*TableLatency
+
+func (s mapSource) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	latency, ok := s[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %s", table)
+	}
+	return latency, nil
+}
+
+var (
+	summaryTable  = Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "school_summary"}
+	schoolsTable  = Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "schools"}
+	sectionsTable = Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "sections"}
+	loadsTable    = Table{Database: alcs.AnalyticsDatabaseRdsInternal, Schema: "audit", Name: "loads"}
+)
+
+func TestParseDependencyGraph(t *testing.T) {
+	graph, err := ParseDependencyGraph([]byte(`
+- database: RedshiftFast
+  schema: public
+  table: school_summary
+  upstreams:
+    - {schema: public, table: schools}
+    - {schema: public, table: sections}
+    - {database: RdsInternal, schema: audit, table: loads}
+    - {schema: public, table: schools}
+`))
+	require.NoError(t, err)
+	require.Equal(t, []Table{schoolsTable, sectionsTable, loadsTable}, graph.Upstreams(summaryTable))
+	require.Empty(t, graph.Upstreams(schoolsTable))
+
+	for _, spec := range []struct {
+		yaml    string
+		wantErr string
+	}{
+		{yaml: `- {schema: public, table: summary}`, wantErr: "table 0: database, schema and table are required"},
+		{yaml: `- {database: RedshiftFast, schema: public, table: summary, upstreams: [{table: schools}]}`, wantErr: "upstream 0: schema and table are required"},
+		{yaml: `- {database: RedshiftFast, schema: public, table: summary, upstream: []}`, wantErr: "field upstream not found"},
+		{
+			yaml:    `- {database: RedshiftFast, schema: public, table: summary, upstreams: [{schema: public, table: schools, upstreams: [{schema: public, table: raw}]}]}`,
+			wantErr: "declare the upstreams of public.schools as its own table",
+		},
+	} {
+		_, err := ParseDependencyGraph([]byte(spec.yaml))
+		require.Error(t, err, spec.yaml)
+		require.Contains(t, err.Error(), spec.wantErr)
+	}
+}
+
+func TestLoadDependencyGraph(t *testing.T) {
+	path := writeTempFile(t, "- {database: RedshiftFast, schema: public, table: school_summary, upstreams: [{schema: public, table: schools}]}\n")
+	defer os.Remove(path)
+	graph, err := LoadDependencyGraph(path)
+	require.NoError(t, err)
+	require.Equal(t, []Table{schoolsTable}, graph.Upstreams(summaryTable))
+
+	_, err = LoadDependencyGraph("/does/not/exist.yml")
+	require.Error(t, err)
+}
+
+func TestCheckDependencies(t *testing.T) {
+	graph := NewDependencyGraph()
+	graph.Add(summaryTable, schoolsTable, sectionsTable)
+
+	refresh := &alcs.Thresholds{Refresh: "10h"}
+	latency := func(hours float64) *TableLatency {
+		return &TableLatency{Latency: &hours, Thresholds: refresh}
+	}
+	for _, spec := range []struct {
+		description string
+		source      mapSource
+		table       Table
+		reason      DependencyReason
+		changed     []Table
+		rebuild     bool
+	}{
+		{
+			description: "fresh",
+			source:      mapSource{summaryTable: latency(5)},
+			table:       summaryTable,
+			reason:      DependencyFresh,
+			changed:     []Table{},
+		},
+		{
+			description: "stale and an upstream has new data",
+			source:      mapSource{summaryTable: latency(15), schoolsTable: latency(20), sectionsTable: latency(2)},
+			table:       summaryTable,
+			reason:      DependencyUpstreamChanged,
+			changed:     []Table{sectionsTable},
+			rebuild:     true,
+		},
+		{
+			description: "stale but upstreams unchanged",
+			source:      mapSource{summaryTable: latency(15), schoolsTable: latency(20), sectionsTable: latency(30)},
+			table:       summaryTable,
+			reason:      DependencyUpstreamUnchanged,
+			changed:     []Table{},
+		},
+		{
+			description: "an upstream that can't be looked up may have changed",
+			source:      mapSource{summaryTable: latency(15), schoolsTable: latency(20)},
+			table:       summaryTable,
+			reason:      DependencyUnknown,
+			changed:     []Table{},
+			rebuild:     true,
+		},
+		{
+			description: "a changed upstream wins over an unknown one",
+			source:      mapSource{summaryTable: latency(15), schoolsTable: latency(2)},
+			table:       summaryTable,
+			reason:      DependencyUpstreamChanged,
+			changed:     []Table{schoolsTable},
+			rebuild:     true,
+		},
+		{
+			description: "no upstreams declared",
+			source:      mapSource{schoolsTable: latency(15)},
+			table:       schoolsTable,
+			reason:      DependencyUnknown,
+			changed:     []Table{},
+			rebuild:     true,
+		},
+		{
+			description: "target latency unknown",
+			source:      mapSource{summaryTable: {Thresholds: refresh}},
+			table:       summaryTable,
+			reason:      DependencyUnknown,
+			changed:     []Table{},
+			rebuild:     true,
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			logger := newRecordingLogger()
+			checker := &FreshnessChecker{Logger: logger, Source: spec.source}
+			result := checker.CheckDependencies(context.Background(), graph, spec.table)
+			require.Equal(t, spec.reason, result.Reason)
+			require.Equal(t, spec.changed, result.Changed)
+			require.Equal(t, spec.rebuild, result.ShouldRebuild())
+			require.Equal(t, []string{"latency-dependencies"}, logger.Titles())
+			require.Equal(t, string(spec.reason), logger.data[0]["reason"])
+		})
+	}
+	checker := &FreshnessChecker{Logger: newRecordingLogger(), Source: mapSource{summaryTable: latency(15)}}
+	result := checker.CheckDependencies(context.Background(), nil, summaryTable)
+	require.Equal(t, DependencyUnknown, result.Reason, "a nil graph has no upstreams")
+	require.True(t, result.ShouldRebuild())
+}
@@ -0,0 +1,76 @@
+package analyticspipeline
+
+import "sync"
+
+// FailureMode says which way a freshness check leans when it cannot reach a decision: when ALCS
+// errors or times out, or the table has no latency, no refresh threshold or an invalid one.
+type FailureMode int
+
+// Failure modes
+const (
+	// FailOpen reports inconclusive checks as not fresh, so the job runs. This is the default.
+	FailOpen FailureMode = iota
+	// FailClosed reports inconclusive checks as fresh, so the job is skipped. Note that this also
+	// skips tables that have no latency or refresh threshold configured at all.
+	FailClosed
+	// FailClosedAfterN fails open for the first N errors or timeouts in a row, then fails closed
+	// on errors and timeouts until any other result comes back. It keeps an ALCS outage from
+	// triggering a stampede of rebuilds, while letting isolated errors run the job. Other
+	// inconclusive checks, e.g. a table without a refresh threshold, always fail open, since they
+	// are configuration, not outages.
+	FailClosedAfterN
+)
+
+func (m FailureMode) String() string {
+	switch m {
+	case FailOpen:
+		return "fail-open"
+	case FailClosed:
+		return "fail-closed"
+	case FailClosedAfterN:
+		return "fail-closed-after-n"
+	default:
+		return "unknown"
+	}
+}
+
+// FailurePolicy decides how inconclusive freshness checks are treated. The zero value fails open.
+// A policy is safe for concurrent use; share one across checks so that FailClosedAfterN counts
+// consecutive failures process-wide.
+type FailurePolicy struct {
+	Mode FailureMode
+	// N is the number of consecutive errors and timeouts FailClosedAfterN tolerates.
+	N int
+
+	lock        sync.Mutex
+	consecutive int
+}
+
+// record counts a check's result towards consecutive outages, and reports whether the check should
+// fail closed. Only errors and timeouts count; any other result resets the count.
+func (p *FailurePolicy) record(reason FreshnessReason) bool {
+	if p == nil {
+		return false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	outage := reason == ReasonError || reason == ReasonTimeout
+	if outage {
+		p.consecutive++
+	} else {
+		p.consecutive = 0
+	}
+	if reason == ReasonFresh || reason == ReasonStale {
+		return false
+	}
+
+	switch p.Mode {
+	case FailClosed:
+		return true
+	case FailClosedAfterN:
+		return outage && p.consecutive > p.N
+	default:
+		return false
+	}
+}
@@ -0,0 +1,104 @@
+package analyticspipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailurePolicy(t *testing.T) {
+	var nilPolicy *FailurePolicy
+	require.False(t, nilPolicy.record(ReasonError))
+
+	open := &FailurePolicy{}
+	require.False(t, open.record(ReasonError))
+
+	closed := &FailurePolicy{Mode: FailClosed}
+	require.True(t, closed.record(ReasonError))
+	require.True(t, closed.record(ReasonNoThreshold))
+	require.False(t, closed.record(ReasonStale))
+
+	afterN := &FailurePolicy{Mode: FailClosedAfterN, N: 2}
+	require.False(t, afterN.record(ReasonError))
+	require.False(t, afterN.record(ReasonTimeout))
+	require.True(t, afterN.record(ReasonError))
+	require.True(t, afterN.record(ReasonTimeout))
+	require.False(t, afterN.record(ReasonStale))
+	require.False(t, afterN.record(ReasonError), "a conclusive check resets the count")
+
+	afterN = &FailurePolicy{Mode: FailClosedAfterN, N: 1}
+	for i := 0; i < 3; i++ {
+		require.False(t, afterN.record(ReasonNoThreshold), "tables without thresholds are not outages")
+		require.False(t, afterN.record(ReasonNoLatency))
+	}
+	require.False(t, afterN.record(ReasonError))
+	require.False(t, afterN.record(ReasonNoLatency), "fails open, and resets the count")
+	require.False(t, afterN.record(ReasonError))
+	require.True(t, afterN.record(ReasonError))
+}
+
+func TestCheckTableFreshnessFailurePolicy(t *testing.T) {
+	connErr := errors.New("connection error")
+	for _, spec := range []struct {
+		description string
+		policy      *FailurePolicy
+		responses   []*alcs.GetTableLatencyResponse
+		want        []bool
+	}{
+		{
+			description: "fail open",
+			policy:      &FailurePolicy{Mode: FailOpen},
+			responses:   []*alcs.GetTableLatencyResponse{nil, nil},
+			want:        []bool{false, false},
+		},
+		{
+			description: "fail closed on errors and missing latency",
+			policy:      &FailurePolicy{Mode: FailClosed},
+			responses:   []*alcs.GetTableLatencyResponse{nil, {Thresholds: &alcs.Thresholds{Refresh: "10h"}}},
+			want:        []bool{true, true},
+		},
+		{
+			description: "fail closed still runs stale tables",
+			policy:      &FailurePolicy{Mode: FailClosed},
+			responses:   []*alcs.GetTableLatencyResponse{{Latency: floatPtr(15), Thresholds: &alcs.Thresholds{Refresh: "10h"}}},
+			want:        []bool{false},
+		},
+		{
+			description: "fail closed after two consecutive errors",
+			policy:      &FailurePolicy{Mode: FailClosedAfterN, N: 2},
+			responses: []*alcs.GetTableLatencyResponse{
+				nil, nil, nil, nil,
+				{Latency: floatPtr(15), Thresholds: &alcs.Thresholds{Refresh: "10h"}},
+				nil,
+			},
+			want: []bool{false, false, true, true, false, false},
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockALCS := alcsWagClient.NewMockClient(mockCtrl)
+			for _, resp := range spec.responses {
+				var err error
+				if resp == nil {
+					err = connErr
+				}
+				mockALCS.EXPECT().GetTableLatency(gomock.Any(), gomock.Any()).Return(resp, err)
+			}
+
+			logger := newRecordingLogger()
+			checker := &FreshnessChecker{Logger: logger, Client: mockALCS, Policy: spec.policy}
+			for i, want := range spec.want {
+				got := checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRedshiftFast, "schema", "table")
+				require.Equal(t, want, got.Fresh, "check %d", i)
+				_, failedClosed := logger.data[i]["failed_closed"]
+				require.Equal(t, want && got.Reason != ReasonFresh, failedClosed, "check %d", i)
+			}
+		})
+	}
+}
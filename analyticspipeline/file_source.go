@@ -0,0 +1,76 @@
+package analyticspipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+)
+
+// FileSource looks up table latencies in a JSON file of last-load timestamps, for local
+// development and tests. The file is read on every lookup, so it can be edited while workers run:
+//
+//	{
+//	  "tables": [
+//	    {
+//	      "database": "RedshiftFast",
+//	      "schema": "public",
+//	      "table": "schools",
+//	      "last_loaded": "2020-06-01T12:00:00Z",
+//	      "thresholds": {"refresh": "10h"}
+//	    }
+//	  ]
+//	}
+//
+// An entry without a database matches the table in any database. Tables missing from the file, or
+// without a last_loaded timestamp, have no latency.
+type FileSource struct {
+	Path string
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// fileSourceEntry is a table in a FileSource file.
+type fileSourceEntry struct {
+	Database   alcs.AnalyticsDatabase `json:"database"`
+	Schema     string                 `json:"schema"`
+	Table      string                 `json:"table"`
+	LastLoaded time.Time              `json:"last_loaded"`
+	Thresholds *alcs.Thresholds       `json:"thresholds"`
+}
+
+// TableLatency reads the file and returns the hours since the table was last loaded.
+func (s *FileSource) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	raw, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Tables []fileSourceEntry `json:"tables"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", s.Path, err)
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	for _, entry := range file.Tables {
+		if entry.Schema != table.Schema || entry.Table != table.Name {
+			continue
+		}
+		if entry.Database != "" && entry.Database != table.Database {
+			continue
+		}
+		if entry.LastLoaded.IsZero() {
+			return &TableLatency{Thresholds: entry.Thresholds}, nil
+		}
+		latency := now().Sub(entry.LastLoaded).Hours()
+		return &TableLatency{Latency: &latency, Thresholds: entry.Thresholds}, nil
+	}
+	return &TableLatency{}, nil
+}
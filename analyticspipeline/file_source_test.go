@@ -0,0 +1,86 @@
+package analyticspipeline
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTempFile(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "analytics-util-test")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(contents)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func TestFileSource(t *testing.T) {
+	path := writeTempFile(t, `{
+		"tables": [
+			{"database": "RedshiftFast", "schema": "public", "table": "schools", "last_loaded": "2020-06-01T01:00:00Z", "thresholds": {"refresh": "10h"}},
+			{"schema": "public", "table": "sections", "last_loaded": "2020-06-01T11:30:00Z"},
+			{"schema": "public", "table": "students", "thresholds": {"refresh": "1h"}}
+		]
+	}`)
+	defer os.Remove(path)
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	source := &FileSource{Path: path, Now: func() time.Time { return now }}
+
+	for _, spec := range []struct {
+		description string
+		table       Table
+		want        *TableLatency
+	}{
+		{
+			description: "matches database, schema and table",
+			table:       Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "schools"},
+			want:        &TableLatency{Latency: floatPtr(11), Thresholds: &alcs.Thresholds{Refresh: "10h"}},
+		},
+		{
+			description: "database is a different table",
+			table:       Table{Database: alcs.AnalyticsDatabaseRedshiftProd, Schema: "public", Name: "schools"},
+			want:        &TableLatency{},
+		},
+		{
+			description: "no database matches any database",
+			table:       Table{Database: alcs.AnalyticsDatabaseRedshiftProd, Schema: "public", Name: "sections"},
+			want:        &TableLatency{Latency: floatPtr(0.5)},
+		},
+		{
+			description: "no last_loaded means no latency",
+			table:       Table{Database: alcs.AnalyticsDatabaseRedshiftProd, Schema: "public", Name: "students"},
+			want:        &TableLatency{Thresholds: &alcs.Thresholds{Refresh: "1h"}},
+		},
+		{
+			description: "missing table",
+			table:       Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "teachers"},
+			want:        &TableLatency{},
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			got, err := source.TableLatency(context.Background(), spec.table)
+			require.NoError(t, err)
+			require.Equal(t, spec.want, got)
+		})
+	}
+
+	checker := &FreshnessChecker{Logger: newRecordingLogger(), Source: source}
+	require.Equal(t, ReasonStale, checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRedshiftFast, "public", "schools").Reason)
+}
+
+func TestFileSourceErrors(t *testing.T) {
+	_, err := (&FileSource{Path: "/does/not/exist.json"}).TableLatency(context.Background(), Table{})
+	require.Error(t, err)
+
+	path := writeTempFile(t, `{"tables": `)
+	defer os.Remove(path)
+	_, err = (&FileSource{Path: path}).TableLatency(context.Background(), Table{})
+	require.Contains(t, err.Error(), "parsing "+path)
+}
@@ -0,0 +1,238 @@
+package analyticspipeline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	alcsHelpers "github.com/Clever/analytics-latency-config-service/helpers"
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+)
+
+var errNoLatencySource = errors.New("freshness checker has no source or client")
+
+// FreshnessChecker checks with ALCS whether table data is fresh enough to skip a job.
+type FreshnessChecker struct {
+	Logger kvlogger.KayveeLogger
+	Client alcsWagClient.Client
+	// Source looks up table latencies. A nil Source asks ALCS through Client, and every check errors
+	// if both are nil.
+	Source LatencySource
+	// Timeout bounds every ALCS call. Zero means calls are only bounded by the context passed in.
+	Timeout time.Duration
+	// Policy decides whether checks that cannot reach a decision fail open or closed. A nil
+	// Policy fails open.
+	Policy *FailurePolicy
+	// Metrics, if set, makes every check emit latency gauges and an outcome counter.
+	Metrics *FreshnessMetrics
+}
+
+// NewFreshnessCheckerFromDiscovery returns a checker that asks the ALCS found through discovery.
+func NewFreshnessCheckerFromDiscovery(logger kvlogger.KayveeLogger) (*FreshnessChecker, error) {
+	client, err := alcsWagClient.NewFromDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	return &FreshnessChecker{Logger: logger, Client: client}, nil
+}
+
+// IsTableDataFreshContext does the same as IsTableDataFresh, except the ALCS call is bound to ctx
+// and gives up after timeout. A timeout of zero means no timeout. Running out of time is logged as
+// latency-debounce-timeout and, like any other error, reports the data as not fresh. ctx being
+// cancelled or reaching its own deadline is logged as latency-debounce-error.
+func IsTableDataFreshContext(
+	ctx context.Context,
+	logger kvlogger.KayveeLogger,
+	alcsClient alcsWagClient.Client,
+	database alcs.AnalyticsDatabase,
+	schema string,
+	table string,
+	timeout time.Duration,
+) bool {
+	checker := &FreshnessChecker{Logger: logger, Client: alcsClient, Timeout: timeout}
+	return checker.IsTableDataFresh(ctx, database, schema, table)
+}
+
+// FreshnessReason explains the outcome of a freshness check.
+type FreshnessReason string
+
+// Freshness check outcomes
+const (
+	// ReasonFresh means the latency is under the refresh threshold.
+	ReasonFresh FreshnessReason = "fresh"
+	// ReasonStale means the latency crossed the refresh threshold.
+	ReasonStale FreshnessReason = "stale"
+	// ReasonError means ALCS could not be reached or returned an error.
+	ReasonError FreshnessReason = "error"
+	// ReasonTimeout means ALCS did not answer within the checker's timeout.
+	ReasonTimeout FreshnessReason = "timeout"
+	// ReasonNoLatency means ALCS has no latency recorded for the table.
+	ReasonNoLatency FreshnessReason = "no-latency"
+	// ReasonNoThreshold means the table has no refresh threshold configured.
+	ReasonNoThreshold FreshnessReason = "no-threshold"
+	// ReasonInvalidThreshold means the table's refresh threshold could not be parsed.
+	ReasonInvalidThreshold FreshnessReason = "invalid-threshold"
+)
+
+// FreshnessResult is the outcome of a freshness check.
+type FreshnessResult struct {
+	Reason FreshnessReason
+	// Fresh is the decision: true means the table's data is fresh and the job can be skipped.
+	Fresh bool
+	// Latency is the table's latency in hours, or nil if it is unknown.
+	Latency *float64
+	// Threshold is the refresh threshold as configured in ALCS, e.g. "10h".
+	Threshold string
+	// ThresholdValue is the refresh threshold in the same unit as Latency. It is only set for
+	// ReasonFresh and ReasonStale.
+	ThresholdValue float64
+	// Err is the error behind ReasonError, ReasonTimeout and ReasonInvalidThreshold.
+	Err error
+}
+
+// IsTableDataFresh checks with ALCS to see if the table data is fresh.
+// Any errors result in a log + "the data is not fresh" to fail gracefully.
+func (c *FreshnessChecker) IsTableDataFresh(ctx context.Context, database alcs.AnalyticsDatabase, schema, table string) bool {
+	return c.CheckTableFreshness(ctx, database, schema, table).Fresh
+}
+
+// CheckTableFreshness checks with ALCS to see if the table data is fresh, and reports why. Any
+// errors result in a log + "the data is not fresh" to fail gracefully, unless the checker's Policy
+// says otherwise.
+func (c *FreshnessChecker) CheckTableFreshness(ctx context.Context, database alcs.AnalyticsDatabase, schema, table string) FreshnessResult {
+	result, title, logPayload := c.check(ctx, Table{Database: database, Schema: schema, Name: table})
+	c.Logger.InfoD(title, logPayload)
+	return result
+}
+
+// check evaluates a table and applies the checker's Policy to the result. It emits the checker's
+// Metrics, but leaves logging the result to the caller.
+func (c *FreshnessChecker) check(ctx context.Context, table Table) (FreshnessResult, string, kvlogger.M) {
+	result, title, logPayload := c.evaluate(ctx, table)
+	if c.Policy.record(result.Reason) {
+		result.Fresh = true
+		logPayload["failed_closed"] = true
+	}
+	c.Metrics.emit(c.Logger, table, result)
+	return result, title, logPayload
+}
+
+// evaluate checks the latency of a table against its refresh threshold, failing open. It returns
+// the result along with the title and payload to log it with.
+func (c *FreshnessChecker) evaluate(ctx context.Context, table Table) (FreshnessResult, string, kvlogger.M) {
+	logPayload := kvlogger.M{
+		"database": table.Database,
+		"schema":   table.Schema,
+		"table":    table.Name,
+	}
+
+	latency, err := c.getTableLatency(ctx, table)
+	if err == nil && latency.Thresholds == nil {
+		// a source that knows nothing about thresholds is the same as no threshold configured
+		latency.Thresholds = &alcs.Thresholds{Refresh: alcsHelpers.NoLatencyAlert}
+	}
+	if err == nil && !latency.CachedAt.IsZero() {
+		logPayload["cache_hit"] = true
+		logPayload["cached_at"] = latency.CachedAt.Format(time.RFC3339)
+	}
+
+	if err == context.DeadlineExceeded && c.Timeout > 0 && ctx.Err() == nil {
+		// A hung ALCS call is treated like any other error, and the job runs. The caller's own
+		// deadline is not a timeout of the check, and is reported like a cancellation below.
+		logPayload["error"] = err
+		logPayload["timeout"] = c.Timeout.String()
+		return FreshnessResult{Reason: ReasonTimeout, Err: err}, "latency-debounce-timeout", logPayload
+	} else if err != nil {
+		// If we get an error, assume latency check fails, and run the job.
+		logPayload["error"] = err
+		return FreshnessResult{Reason: ReasonError, Err: err}, "latency-debounce-error", logPayload
+	} else if latency.Latency == nil {
+		// No latency returned means that we should run the job.
+		logPayload["threshold"] = latency.Thresholds.Refresh
+		logPayload["latency"] = "not found"
+		return FreshnessResult{Reason: ReasonNoLatency, Threshold: latency.Thresholds.Refresh}, "latency-debounce-empty", logPayload
+	} else if latency.Thresholds.Refresh == alcsHelpers.NoLatencyAlert {
+		// No refresh latency specified means that we should run the job.
+		logPayload["threshold"] = "none"
+		logPayload["latency"] = *latency.Latency
+		result := FreshnessResult{Reason: ReasonNoThreshold, Latency: latency.Latency, Threshold: latency.Thresholds.Refresh}
+		return result, "latency-debounce-unset", logPayload
+	} else {
+		result := FreshnessResult{Latency: latency.Latency, Threshold: latency.Thresholds.Refresh}
+		crossed, threshold, err := alcsHelpers.CheckThresholdCrossed(*latency.Latency, latency.Thresholds, alcs.ThresholdTierRefresh)
+		logPayload["threshold"] = threshold
+		logPayload["latency"] = *latency.Latency
+
+		// Again, if we had an error, fall back to running the job
+		if err != nil {
+			result.Reason = ReasonInvalidThreshold
+			result.Err = err
+			logPayload["error"] = err
+		} else if !crossed {
+			// We don't need to run the task. Bail out early.
+			result.Reason = ReasonFresh
+			result.Fresh = true
+			result.ThresholdValue = float64(threshold)
+			return result, "latency-debounce-fresh", logPayload
+		} else {
+			result.Reason = ReasonStale
+			result.ThresholdValue = float64(threshold)
+		}
+		// Otherwise, run the job
+		return result, "latency-debounce-stale", logPayload
+	}
+}
+
+// source returns the checker's LatencySource, falling back to ALCS. It is an error to have neither.
+func (c *FreshnessChecker) source() (LatencySource, error) {
+	if c.Source != nil {
+		return c.Source, nil
+	}
+	if c.Client == nil {
+		return nil, errNoLatencySource
+	}
+	return &ALCSSource{Client: c.Client}, nil
+}
+
+// getTableLatency asks the checker's source for a table's latency, giving up once ctx is done or
+// the checker's timeout passes, even if the source itself ignores the context.
+func (c *FreshnessChecker) getTableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	source, err := c.source()
+	if err != nil {
+		return nil, err
+	}
+
+	type response struct {
+		latency *TableLatency
+		err     error
+	}
+	// buffered so the call can finish in the background after we give up on it
+	done := make(chan response, 1)
+	go func() {
+		latency, err := source.TableLatency(ctx, table)
+		done <- response{latency: latency, err: err}
+	}()
+
+	select {
+	case resp := <-done:
+		if resp.err != nil && ctx.Err() == context.DeadlineExceeded {
+			return nil, context.DeadlineExceeded
+		}
+		if resp.err == nil && resp.latency == nil {
+			return &TableLatency{}, nil
+		}
+		return resp.latency, resp.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
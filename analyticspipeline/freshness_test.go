@@ -0,0 +1,229 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	alcsHelpers "github.com/Clever/analytics-latency-config-service/helpers"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+)
+
+// recordingLogger records the titles of InfoD logs so tests can check which outcome was logged.
+type recordingLogger struct {
+	kvlogger.KayveeLogger
+	lock   sync.Mutex
+	titles []string
+	data   []map[string]interface{}
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{KayveeLogger: kvlogger.NewMockCountLogger("freshness-test")}
+}
+
+func (l *recordingLogger) InfoD(title string, data map[string]interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.titles = append(l.titles, title)
+	l.data = append(l.data, data)
+}
+
+func (l *recordingLogger) Titles() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]string{}, l.titles...)
+}
+
+func freshResponse() *alcs.GetTableLatencyResponse {
+	return &alcs.GetTableLatencyResponse{
+		Latency:    floatPtr(5),
+		Thresholds: &alcs.Thresholds{Refresh: "10h"},
+	}
+}
+
+func TestIsTableDataFreshContext(t *testing.T) {
+	for _, spec := range []struct {
+		description string
+		timeout     time.Duration
+		deadline    time.Duration
+		cancel      bool
+		// release is closed once the check is done, so that calls that hang can return
+		call    func(ctx context.Context, release <-chan struct{}) (*alcs.GetTableLatencyResponse, error)
+		isFresh bool
+		title   string
+	}{
+		{
+			description: "answers within the timeout",
+			timeout:     time.Second,
+			call: func(ctx context.Context, release <-chan struct{}) (*alcs.GetTableLatencyResponse, error) {
+				return freshResponse(), nil
+			},
+			isFresh: true,
+			title:   "latency-debounce-fresh",
+		},
+		{
+			description: "times out when the client honors the deadline",
+			timeout:     10 * time.Millisecond,
+			call: func(ctx context.Context, release <-chan struct{}) (*alcs.GetTableLatencyResponse, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			title: "latency-debounce-timeout",
+		},
+		{
+			description: "times out when the client ignores the deadline",
+			timeout:     10 * time.Millisecond,
+			call: func(ctx context.Context, release <-chan struct{}) (*alcs.GetTableLatencyResponse, error) {
+				<-release
+				return freshResponse(), nil
+			},
+			title: "latency-debounce-timeout",
+		},
+		{
+			description: "the caller's deadline is an error",
+			deadline:    10 * time.Millisecond,
+			call: func(ctx context.Context, release <-chan struct{}) (*alcs.GetTableLatencyResponse, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			title: "latency-debounce-error",
+		},
+		{
+			description: "the caller's deadline is an error even with a timeout",
+			timeout:     time.Second,
+			deadline:    10 * time.Millisecond,
+			call: func(ctx context.Context, release <-chan struct{}) (*alcs.GetTableLatencyResponse, error) {
+				<-release
+				return freshResponse(), nil
+			},
+			title: "latency-debounce-error",
+		},
+		{
+			description: "cancellation is an error",
+			cancel:      true,
+			call: func(ctx context.Context, release <-chan struct{}) (*alcs.GetTableLatencyResponse, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			title: "latency-debounce-error",
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockALCS := alcsWagClient.NewMockClient(mockCtrl)
+			release := make(chan struct{})
+			returned := make(chan struct{})
+			mockALCS.EXPECT().GetTableLatency(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, req *alcs.GetTableLatencyRequest) (*alcs.GetTableLatencyResponse, error) {
+					defer close(returned)
+					return spec.call(ctx, release)
+				},
+			).MaxTimes(1)
+			defer func() {
+				// don't leave the call running in the background once the test is over
+				close(release)
+				if !spec.cancel {
+					<-returned
+				}
+			}()
+			logger := newRecordingLogger()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if spec.deadline > 0 {
+				ctx, cancel = context.WithTimeout(ctx, spec.deadline)
+				defer cancel()
+			}
+			if spec.cancel {
+				cancel()
+			}
+
+			start := time.Now()
+			fresh := IsTableDataFreshContext(ctx, logger, mockALCS, alcs.AnalyticsDatabaseRedshiftFast, "schema", "table", spec.timeout)
+			require.Equal(t, spec.isFresh, fresh)
+			require.Equal(t, []string{spec.title}, logger.Titles())
+			require.True(t, time.Since(start) < 500*time.Millisecond, "the check should not wait on a hung call")
+		})
+	}
+}
+
+func TestCheckTableFreshness(t *testing.T) {
+	connErr := fmt.Errorf("connection error")
+	for _, spec := range []struct {
+		description string
+		refresh     string
+		latency     *float64
+		queryError  error
+		want        FreshnessResult
+	}{
+		{
+			description: "fresh",
+			refresh:     "10h",
+			latency:     floatPtr(5),
+			want:        FreshnessResult{Reason: ReasonFresh, Fresh: true, Latency: floatPtr(5), Threshold: "10h", ThresholdValue: 10},
+		},
+		{
+			description: "stale",
+			refresh:     "10h",
+			latency:     floatPtr(15),
+			want:        FreshnessResult{Reason: ReasonStale, Latency: floatPtr(15), Threshold: "10h", ThresholdValue: 10},
+		},
+		{
+			description: "no threshold",
+			refresh:     alcsHelpers.NoLatencyAlert,
+			latency:     floatPtr(5),
+			want:        FreshnessResult{Reason: ReasonNoThreshold, Latency: floatPtr(5), Threshold: alcsHelpers.NoLatencyAlert},
+		},
+		{
+			description: "no latency",
+			refresh:     "10h",
+			want:        FreshnessResult{Reason: ReasonNoLatency, Threshold: "10h"},
+		},
+		{
+			description: "alcs error",
+			queryError:  connErr,
+			want:        FreshnessResult{Reason: ReasonError, Err: connErr},
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockALCS := alcsWagClient.NewMockClient(mockCtrl)
+
+			var latencyResp *alcs.GetTableLatencyResponse
+			if spec.queryError == nil {
+				latencyResp = &alcs.GetTableLatencyResponse{Latency: spec.latency, Thresholds: &alcs.Thresholds{Refresh: spec.refresh}}
+			}
+			mockALCS.EXPECT().GetTableLatency(gomock.Any(), gomock.Any()).Return(latencyResp, spec.queryError)
+
+			checker := &FreshnessChecker{Logger: newRecordingLogger(), Client: mockALCS}
+			got := checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRedshiftFast, "schema", "table")
+			require.Equal(t, spec.want, got)
+		})
+	}
+}
+
+func TestCheckTableFreshnessInvalidThreshold(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockALCS := alcsWagClient.NewMockClient(mockCtrl)
+	mockALCS.EXPECT().GetTableLatency(gomock.Any(), gomock.Any()).Return(&alcs.GetTableLatencyResponse{
+		Latency:    floatPtr(5),
+		Thresholds: &alcs.Thresholds{Refresh: "10j"},
+	}, nil)
+
+	logger := newRecordingLogger()
+	checker := &FreshnessChecker{Logger: logger, Client: mockALCS}
+	got := checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRedshiftFast, "schema", "table")
+	require.Equal(t, ReasonInvalidThreshold, got.Reason)
+	require.False(t, got.Fresh)
+	require.Error(t, got.Err)
+	require.Equal(t, []string{"latency-debounce-stale"}, logger.Titles())
+}
@@ -0,0 +1,45 @@
+package analyticspipeline
+
+import (
+	"context"
+	"time"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+)
+
+// TableLatency is how stale a table is, and how stale it is allowed to get.
+type TableLatency struct {
+	// Latency is the time since the table was last loaded, in hours, or nil if it is unknown.
+	Latency *float64
+	// Thresholds are the table's thresholds, in the format ALCS uses, e.g. "10h". Nil means the
+	// table has none.
+	Thresholds *alcs.Thresholds
+	// CachedAt is when the latency was cached, or zero if it was looked up for this check.
+	CachedAt time.Time
+}
+
+// LatencySource looks up the latency of tables, so that freshness checks can work on tables ALCS
+// does not track. A nil *TableLatency with a nil error means the table has no latency or
+// thresholds, like an empty one.
+type LatencySource interface {
+	TableLatency(ctx context.Context, table Table) (*TableLatency, error)
+}
+
+// ALCSSource looks up table latencies in ALCS.
+type ALCSSource struct {
+	Client alcsWagClient.Client
+}
+
+// TableLatency asks ALCS for the latency and thresholds of a table.
+func (s *ALCSSource) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	resp, err := s.Client.GetTableLatency(ctx, &alcs.GetTableLatencyRequest{
+		Database: table.Database,
+		Schema:   &table.Schema,
+		Table:    &table.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TableLatency{Latency: resp.Latency, Thresholds: resp.Thresholds}, nil
+}
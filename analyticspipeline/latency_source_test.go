@@ -0,0 +1,92 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestALCSSource(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockALCS := alcsWagClient.NewMockClient(mockCtrl)
+
+	schema, table := "schema", "table"
+	thresholds := &alcs.Thresholds{Refresh: "10h"}
+	mockALCS.EXPECT().GetTableLatency(context.TODO(), &alcs.GetTableLatencyRequest{
+		Database: alcs.AnalyticsDatabaseRedshiftFast,
+		Schema:   &schema,
+		Table:    &table,
+	}).Return(&alcs.GetTableLatencyResponse{Latency: floatPtr(5), Thresholds: thresholds}, nil)
+	mockALCS.EXPECT().GetTableLatency(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("connection error"))
+
+	source := &ALCSSource{Client: mockALCS}
+	latency, err := source.TableLatency(context.TODO(), Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: schema, Name: table})
+	require.NoError(t, err)
+	require.Equal(t, &TableLatency{Latency: floatPtr(5), Thresholds: thresholds}, latency)
+
+	_, err = source.TableLatency(context.TODO(), Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: schema, Name: table})
+	require.EqualError(t, err, "connection error")
+}
+
+// staticSource returns the same latency for every table.
+type staticSource struct {
+	latency *TableLatency
+	err     error
+}
+
+func (s staticSource) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	return s.latency, s.err
+}
+
+func TestFreshnessCheckerSource(t *testing.T) {
+	for _, spec := range []struct {
+		description string
+		source      staticSource
+		reason      FreshnessReason
+	}{
+		{
+			description: "fresh",
+			source:      staticSource{latency: &TableLatency{Latency: floatPtr(5), Thresholds: &alcs.Thresholds{Refresh: "10h"}}},
+			reason:      ReasonFresh,
+		},
+		{
+			description: "stale",
+			source:      staticSource{latency: &TableLatency{Latency: floatPtr(15), Thresholds: &alcs.Thresholds{Refresh: "10h"}}},
+			reason:      ReasonStale,
+		},
+		{
+			description: "no thresholds",
+			source:      staticSource{latency: &TableLatency{Latency: floatPtr(5)}},
+			reason:      ReasonNoThreshold,
+		},
+		{
+			description: "nil latency",
+			source:      staticSource{},
+			reason:      ReasonNoLatency,
+		},
+		{
+			description: "error",
+			source:      staticSource{err: fmt.Errorf("no audit table")},
+			reason:      ReasonError,
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			checker := &FreshnessChecker{Logger: newRecordingLogger(), Source: spec.source}
+			result := checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRdsInternal, "audit", "loads")
+			require.Equal(t, spec.reason, result.Reason)
+		})
+	}
+}
+
+func TestFreshnessCheckerWithoutSource(t *testing.T) {
+	checker := &FreshnessChecker{Logger: newRecordingLogger()}
+	result := checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRdsInternal, "audit", "loads")
+	require.Equal(t, ReasonError, result.Reason)
+	require.Equal(t, errNoLatencySource, result.Err)
+}
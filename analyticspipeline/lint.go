@@ -0,0 +1,165 @@
+package analyticspipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// LintSeverity says whether a LintIssue stops a payload from being run.
+type LintSeverity string
+
+// Lint severities
+const (
+	// LintError is a problem that makes AnalyticsWorker or a runner reject the payload.
+	LintError LintSeverity = "error"
+	// LintWarning is a shape that works today but is likely a mistake or is deprecated.
+	LintWarning LintSeverity = "warning"
+)
+
+// LintIssue is a problem found in a payload.
+type LintIssue struct {
+	Severity LintSeverity `json:"severity"`
+	// Path locates the problem in the payload, e.g. "remaining[2].retry".
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// LintPayload checks the shape of a JSON payload without running it. It returns no issues for a
+// well-formed payload.
+func LintPayload(raw []byte) []LintIssue {
+	l := &linter{}
+
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		l.errorf("$", "invalid JSON: %s", err)
+		return l.issues
+	}
+	payload, ok := doc.(map[string]interface{})
+	if !ok {
+		l.errorf("$", "payload must be an object, got %s", jsonType(doc))
+		return l.issues
+	}
+
+	current, hasCurrent := payload["current"]
+	if !hasCurrent || current == nil {
+		l.warnf("$", "payload uses the legacy unwrapped format; wrap the config in \"current\"")
+		l.step("$", payload)
+		return l.issues
+	}
+	if step, ok := current.(map[string]interface{}); ok {
+		l.step("current", step)
+	} else {
+		l.errorf("current", "must be an object, got %s", jsonType(current))
+	}
+
+	remaining, hasRemaining := payload["remaining"]
+	steps, ok := remaining.([]interface{})
+	switch {
+	case !hasRemaining || remaining == nil:
+	case !ok:
+		l.errorf("remaining", "must be a list, got %s", jsonType(remaining))
+	default:
+		for i, s := range steps {
+			path := fmt.Sprintf("remaining[%d]", i)
+			if step, ok := s.(map[string]interface{}); ok {
+				l.step(path, step)
+			} else {
+				l.errorf(path, "must be an object, got %s", jsonType(s))
+			}
+		}
+	}
+
+	if done, ok := payload["done"]; ok {
+		if isDone, ok := done.(bool); !ok {
+			l.errorf("done", "must be a boolean, got %s", jsonType(done))
+		} else if isDone && len(steps) > 0 {
+			l.warnf("done", "is true but %d steps remain", len(steps))
+		}
+	}
+
+	if attempt, ok := payload["attempt"]; ok {
+		if n, ok := attempt.(float64); !ok || n < 0 || n != math.Trunc(n) {
+			l.errorf("attempt", "must be a non-negative integer, got %v", attempt)
+		}
+	}
+
+	for _, key := range sortedKeys(payload) {
+		switch key {
+		case "current", "remaining", "done", "attempt":
+		default:
+			l.warnf(key, "unknown payload attribute is ignored")
+		}
+	}
+	return l.issues
+}
+
+type linter struct {
+	issues []LintIssue
+}
+
+func (l *linter) errorf(path, format string, args ...interface{}) {
+	l.issues = append(l.issues, LintIssue{Severity: LintError, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(path, format string, args ...interface{}) {
+	l.issues = append(l.issues, LintIssue{Severity: LintWarning, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// step checks the reserved keys and config values of a single workflow step.
+func (l *linter) step(path string, step map[string]interface{}) {
+	for _, key := range sortedKeys(step) {
+		switch key {
+		case StepKey, RetryKey, FanOutKey:
+			continue
+		}
+		switch step[key].(type) {
+		case string, bool:
+		default:
+			l.warnf(path+"."+key, "config values must be strings or booleans, got %s", jsonType(step[key]))
+		}
+	}
+	if name, ok := step[StepKey]; ok {
+		if _, ok := name.(string); !ok {
+			l.errorf(path+"."+StepKey, "must be a string, got %s", jsonType(name))
+		}
+	}
+	if _, err := RetryPolicyForStep(step); err != nil {
+		l.errorf(path+"."+RetryKey, "%s", err)
+	}
+	if _, err := ExpandFanOut(step); err != nil {
+		l.errorf(path+"."+FanOutKey, "%s", err)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonType names the JSON type of a decoded value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,99 @@
+package analyticspipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []LintIssue
+	}{
+		{
+			name:    "well formed",
+			payload: `{"current":{"step":"extract","district_id":"abc123"},"remaining":[{"step":"load","retry":{"max_attempts":2}}],"done":false,"attempt":2}`,
+		},
+		{
+			name:    "invalid JSON",
+			payload: `{"current":`,
+			want:    []LintIssue{{Severity: LintError, Path: "$", Message: "invalid JSON: unexpected end of JSON input"}},
+		},
+		{
+			name:    "not an object",
+			payload: `["abc123"]`,
+			want:    []LintIssue{{Severity: LintError, Path: "$", Message: "payload must be an object, got list"}},
+		},
+		{
+			name:    "legacy unwrapped format",
+			payload: `{"district_id":"abc123"}`,
+			want: []LintIssue{{
+				Severity: LintWarning,
+				Path:     "$",
+				Message:  `payload uses the legacy unwrapped format; wrap the config in "current"`,
+			}},
+		},
+		{
+			name:    "non-object current",
+			payload: `{"current":"abc123","remaining":[]}`,
+			want:    []LintIssue{{Severity: LintError, Path: "current", Message: "must be an object, got string"}},
+		},
+		{
+			name:    "remaining entries that aren't objects",
+			payload: `{"current":{},"remaining":[{},"load",null]}`,
+			want: []LintIssue{
+				{Severity: LintError, Path: "remaining[1]", Message: "must be an object, got string"},
+				{Severity: LintError, Path: "remaining[2]", Message: "must be an object, got null"},
+			},
+		},
+		{
+			name:    "remaining is not a list",
+			payload: `{"current":{},"remaining":{"step":"load"}}`,
+			want:    []LintIssue{{Severity: LintError, Path: "remaining", Message: "must be a list, got object"}},
+		},
+		{
+			name:    "bad step keys and config values",
+			payload: `{"current":{"step":3,"limit":10},"remaining":[{"retry":{"max_attempts":-1}},{"fan_out":"a,b"}]}`,
+			want: []LintIssue{
+				{Severity: LintWarning, Path: "current.limit", Message: "config values must be strings or booleans, got number"},
+				{Severity: LintError, Path: "current.step", Message: "must be a string, got number"},
+				{Severity: LintError, Path: "remaining[0].retry", Message: "retry policy max_attempts cannot be negative"},
+				{Severity: LintError, Path: "remaining[1].fan_out", Message: "fan_out must be a list of objects"},
+			},
+		},
+		{
+			name:    "bad done, attempt and unknown attributes",
+			payload: `{"current":{},"remaining":[{}],"done":true,"attempt":1.5,"remainig":[]}`,
+			want: []LintIssue{
+				{Severity: LintWarning, Path: "done", Message: "is true but 1 steps remain"},
+				{Severity: LintError, Path: "attempt", Message: "must be a non-negative integer, got 1.5"},
+				{Severity: LintWarning, Path: "remainig", Message: "unknown payload attribute is ignored"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, LintPayload([]byte(tt.payload)))
+		})
+	}
+}
+
+func TestParsePayload(t *testing.T) {
+	p, err := ParsePayload([]byte(`{"current":{"district_id":"abc123"},"remaining":[{"district_id":"abc456"}]}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"district_id": "abc123"}, p.Current)
+	require.Equal(t, &Payload{
+		Current:    map[string]interface{}{"district_id": "abc456"},
+		Remanining: []map[string]interface{}{},
+	}, p.Next())
+
+	p, err = ParsePayload([]byte(`{"district_id":"abc123"}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"district_id": "abc123"}, p.Current)
+	require.True(t, p.Next().Done)
+
+	_, err = ParsePayload([]byte(`{"district_id":`))
+	require.Equal(t, errInvalidJSON, err)
+}
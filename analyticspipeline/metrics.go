@@ -0,0 +1,80 @@
+package analyticspipeline
+
+import (
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+)
+
+// Default freshness metric names
+const (
+	DefaultLatencyGauge   = "latency-debounce-latency"
+	DefaultRatioGauge     = "latency-debounce-threshold-ratio"
+	DefaultOutcomeCounter = "latency-debounce-outcome"
+)
+
+// Outcomes of the outcome counter
+const (
+	OutcomeFresh = "fresh"
+	OutcomeStale = "stale"
+	OutcomeError = "error"
+	OutcomeUnset = "unset"
+)
+
+// FreshnessMetrics names the kayvee metrics a FreshnessChecker emits for every check, each tagged
+// with the table's database, schema and table. Empty names use the defaults.
+type FreshnessMetrics struct {
+	// LatencyGauge is the table's latency in hours, when it is known.
+	LatencyGauge string
+	// RatioGauge is the latency divided by the refresh threshold, when both are known. Values
+	// over 1 are stale.
+	RatioGauge string
+	// OutcomeCounter counts checks, tagged with their outcome, one of OutcomeFresh, OutcomeStale,
+	// OutcomeError or OutcomeUnset, and with the FreshnessReason behind it as reason.
+	OutcomeCounter string
+}
+
+// emit reports a check's result as metrics through logger. It is a no-op on a nil receiver.
+func (m *FreshnessMetrics) emit(logger kvlogger.KayveeLogger, table Table, result FreshnessResult) {
+	if m == nil {
+		return
+	}
+	tags := func() kvlogger.M {
+		return kvlogger.M{
+			"database": table.Database,
+			"schema":   table.Schema,
+			"table":    table.Name,
+		}
+	}
+
+	if result.Latency != nil {
+		logger.GaugeFloatD(metricName(m.LatencyGauge, DefaultLatencyGauge), *result.Latency, tags())
+		if result.ThresholdValue > 0 {
+			logger.GaugeFloatD(metricName(m.RatioGauge, DefaultRatioGauge), *result.Latency/result.ThresholdValue, tags())
+		}
+	}
+	outcome := tags()
+	outcome["outcome"] = outcomeOf(result.Reason)
+	outcome["reason"] = string(result.Reason)
+	logger.CounterD(metricName(m.OutcomeCounter, DefaultOutcomeCounter), 1, outcome)
+}
+
+// outcomeOf groups reasons into outcomes: a table without a latency or a refresh threshold is
+// unset, and any reason that keeps the check from reaching a decision is an error.
+func outcomeOf(reason FreshnessReason) string {
+	switch reason {
+	case ReasonFresh:
+		return OutcomeFresh
+	case ReasonStale:
+		return OutcomeStale
+	case ReasonNoLatency, ReasonNoThreshold:
+		return OutcomeUnset
+	default:
+		return OutcomeError
+	}
+}
+
+func metricName(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
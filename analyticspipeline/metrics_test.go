@@ -0,0 +1,120 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+// metric is a gauge or counter recorded by metricsLogger.
+type metric struct {
+	title string
+	value float64
+	data  map[string]interface{}
+}
+
+// metricsLogger records gauges and counters.
+type metricsLogger struct {
+	*recordingLogger
+	lock    sync.Mutex
+	metrics []metric
+}
+
+func (l *metricsLogger) GaugeFloatD(title string, value float64, data map[string]interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.metrics = append(l.metrics, metric{title: title, value: value, data: data})
+}
+
+func (l *metricsLogger) CounterD(title string, value int, data map[string]interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.metrics = append(l.metrics, metric{title: title, value: float64(value), data: data})
+}
+
+func TestFreshnessMetrics(t *testing.T) {
+	tags := func(outcome string, reason FreshnessReason) map[string]interface{} {
+		data := map[string]interface{}{"database": alcs.AnalyticsDatabaseRedshiftFast, "schema": "public", "table": "schools"}
+		if outcome != "" {
+			data["outcome"] = outcome
+			data["reason"] = string(reason)
+		}
+		return data
+	}
+	for _, spec := range []struct {
+		description string
+		metrics     *FreshnessMetrics
+		source      staticSource
+		want        []metric
+	}{
+		{
+			description: "no metrics by default",
+			source:      staticSource{latency: &TableLatency{Latency: floatPtr(5), Thresholds: &alcs.Thresholds{Refresh: "10h"}}},
+		},
+		{
+			description: "fresh",
+			metrics:     &FreshnessMetrics{},
+			source:      staticSource{latency: &TableLatency{Latency: floatPtr(5), Thresholds: &alcs.Thresholds{Refresh: "10h"}}},
+			want: []metric{
+				{title: DefaultLatencyGauge, value: 5, data: tags("", "")},
+				{title: DefaultRatioGauge, value: 0.5, data: tags("", "")},
+				{title: DefaultOutcomeCounter, value: 1, data: tags(OutcomeFresh, ReasonFresh)},
+			},
+		},
+		{
+			description: "custom names",
+			metrics:     &FreshnessMetrics{LatencyGauge: "latency", RatioGauge: "ratio", OutcomeCounter: "outcome"},
+			source:      staticSource{latency: &TableLatency{Latency: floatPtr(15), Thresholds: &alcs.Thresholds{Refresh: "10h"}}},
+			want: []metric{
+				{title: "latency", value: 15, data: tags("", "")},
+				{title: "ratio", value: 1.5, data: tags("", "")},
+				{title: "outcome", value: 1, data: tags(OutcomeStale, ReasonStale)},
+			},
+		},
+		{
+			description: "no threshold has no ratio",
+			metrics:     &FreshnessMetrics{},
+			source:      staticSource{latency: &TableLatency{Latency: floatPtr(5)}},
+			want: []metric{
+				{title: DefaultLatencyGauge, value: 5, data: tags("", "")},
+				{title: DefaultOutcomeCounter, value: 1, data: tags(OutcomeUnset, ReasonNoThreshold)},
+			},
+		},
+		{
+			description: "no latency is unset",
+			metrics:     &FreshnessMetrics{},
+			source:      staticSource{latency: &TableLatency{Thresholds: &alcs.Thresholds{Refresh: "10h"}}},
+			want: []metric{
+				{title: DefaultOutcomeCounter, value: 1, data: tags(OutcomeUnset, ReasonNoLatency)},
+			},
+		},
+		{
+			description: "invalid thresholds are errors",
+			metrics:     &FreshnessMetrics{},
+			source:      staticSource{latency: &TableLatency{Latency: floatPtr(5), Thresholds: &alcs.Thresholds{Refresh: "soon"}}},
+			want: []metric{
+				{title: DefaultLatencyGauge, value: 5, data: tags("", "")},
+				{title: DefaultOutcomeCounter, value: 1, data: tags(OutcomeError, ReasonInvalidThreshold)},
+			},
+		},
+		{
+			description: "errors only count",
+			metrics:     &FreshnessMetrics{},
+			source:      staticSource{err: fmt.Errorf("connection error")},
+			want: []metric{
+				{title: DefaultOutcomeCounter, value: 1, data: tags(OutcomeError, ReasonError)},
+			},
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			logger := &metricsLogger{recordingLogger: newRecordingLogger()}
+			checker := &FreshnessChecker{Logger: logger, Source: spec.source, Metrics: spec.metrics}
+			checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRedshiftFast, "public", "schools")
+			require.Equal(t, spec.want, logger.metrics)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package analyticspipeline
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	errNoStepName = errors.New("a step name is required to register a worker")
+	errNilPayload = errors.New("cannot validate a nil payload")
+)
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]reflect.Value{}
+)
+
+// configField is a single attribute of a config struct.
+type configField struct {
+	index       int
+	key         string
+	kind        reflect.Kind
+	required    bool
+	description string
+}
+
+// configFields returns the attributes of a config struct type, applying the same rules as
+// AnalyticsWorker.
+func configFields(configType reflect.Type) ([]configField, error) {
+	if configType.Kind() == reflect.Ptr {
+		configType = configType.Elem()
+	}
+	if configType.Kind() != reflect.Struct {
+		return nil, errStructOnly
+	}
+
+	fields := make([]configField, 0, configType.NumField())
+	for i := 0; i < configType.NumField(); i++ {
+		typedAttr := configType.Field(i)
+		if typedAttr.Type.Kind() != reflect.String && typedAttr.Type.Kind() != reflect.Bool {
+			return nil, errStringAndBoolOnly
+		}
+		key, required, err := parseTagKey(typedAttr.Tag.Get(structTagKey))
+		if err != nil {
+			return nil, err
+		}
+		if required && typedAttr.Type.Kind() == reflect.Bool {
+			return nil, errBoolCannotBeRequired
+		}
+		fields = append(fields, configField{
+			index:       i,
+			key:         key,
+			kind:        typedAttr.Type.Kind(),
+			required:    required,
+			description: typedAttr.Tag.Get(descriptionTagKey),
+		})
+	}
+	return fields, nil
+}
+
+// RegisterWorker records the config struct of the worker that runs step, so that payloads can be
+// checked against it with ValidatePayload. configStruct may be a struct or a pointer to one. Its
+// values are kept as the worker's defaults.
+func RegisterWorker(step string, configStruct interface{}) error {
+	if step == "" {
+		return errNoStepName
+	}
+	if configStruct == nil {
+		return errStructOnly
+	}
+	if _, err := configFields(reflect.TypeOf(configStruct)); err != nil {
+		return fmt.Errorf("invalid config for step %s: %s", step, err)
+	}
+	config := reflect.Indirect(reflect.ValueOf(configStruct))
+	defaults := reflect.New(config.Type()).Elem()
+	defaults.Set(config)
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if existing, ok := registry[step]; ok && existing.Type() != defaults.Type() {
+		return fmt.Errorf("step %s is already registered to %s", step, existing.Type())
+	}
+	registry[step] = defaults
+	return nil
+}
+
+// UnregisterWorker removes the config struct registered for step, if any. It is mostly useful in
+// tests that register workers.
+func UnregisterWorker(step string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	delete(registry, step)
+}
+
+// RegisteredWorkers returns the names of every registered step, sorted.
+func RegisteredWorkers() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	steps := make([]string, 0, len(registry))
+	for step := range registry {
+		steps = append(steps, step)
+	}
+	sort.Strings(steps)
+	return steps
+}
+
+// registeredConfig returns a copy of the config struct registered for step.
+func registeredConfig(step string) (reflect.Value, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	config, ok := registry[step]
+	return config, ok
+}
+
+// PayloadError lists every problem ValidatePayload found in a payload.
+type PayloadError struct {
+	Issues []LintIssue
+}
+
+func (e *PayloadError) Error() string {
+	issues := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		issues[i] = issue.Path + ": " + issue.Message
+	}
+	return "invalid payload: " + strings.Join(issues, "; ")
+}
+
+// ValidatePayload checks the current step and every remaining step of a payload against the
+// config struct registered for it with RegisterWorker. It catches missing required fields and
+// values of the wrong type before the workflow starts, rather than when the step runs. It returns
+// a *PayloadError if any step has a LintError, listing every issue including warnings.
+func ValidatePayload(payload *Payload) error {
+	if payload == nil {
+		return errNilPayload
+	}
+	issues := ValidatePayloadIssues(payload)
+	for _, issue := range issues {
+		if issue.Severity == LintError {
+			return &PayloadError{Issues: issues}
+		}
+	}
+	return nil
+}
+
+// ValidatePayloadIssues does the same checks as ValidatePayload, returning every issue it finds.
+// Keys that no registered config declares are reported as a LintWarning, since AnalyticsWorker
+// ignores them.
+func ValidatePayloadIssues(payload *Payload) []LintIssue {
+	l := &linter{}
+	if payload == nil {
+		l.errorf("$", "%s", errNilPayload)
+		return l.issues
+	}
+	l.validateStep("current", payload.Current)
+	for i, step := range payload.Remanining {
+		l.validateStep(fmt.Sprintf("remaining[%d]", i), step)
+	}
+	return l.issues
+}
+
+func (l *linter) validateStep(path string, step map[string]interface{}) {
+	name := StepName(step)
+	if name == "" {
+		l.errorf(path, "has no %q naming its worker", StepKey)
+		return
+	}
+	config, ok := registeredConfig(name)
+	if !ok {
+		l.errorf(path, "no worker is registered for step %s", name)
+		return
+	}
+	fields, err := configFields(config.Type())
+	if err != nil {
+		l.errorf(path, "%s", err)
+		return
+	}
+	invocations, err := ExpandFanOut(step)
+	if err != nil {
+		l.errorf(path+"."+FanOutKey, "%s", err)
+		return
+	}
+
+	for i, invocation := range invocations {
+		invocationPath := path
+		if _, ok := step[FanOutKey]; ok {
+			invocationPath = fmt.Sprintf("%s.%s[%d]", path, FanOutKey, i)
+		}
+		l.validateConfig(invocationPath, name, fields, config, invocation)
+	}
+}
+
+// validateConfig checks a single invocation of a step the way AnalyticsWorker would parse it.
+func (l *linter) validateConfig(path, name string, fields []configField, defaults reflect.Value, config map[string]interface{}) {
+	known := map[string]bool{StepKey: true, RetryKey: true, FanOutKey: true}
+	for _, field := range fields {
+		known[field.key] = true
+		value, ok := config[field.key]
+		if !ok {
+			if field.required && defaults.Field(field.index).String() == "" {
+				l.errorf(path, "missing required field %s for step %s", field.key, name)
+			}
+			continue
+		}
+
+		switch field.kind {
+		case reflect.String:
+			s, ok := value.(string)
+			if !ok {
+				l.errorf(path+"."+field.key, "must be a string, got %s", jsonType(value))
+			} else if s == "" && field.required {
+				l.errorf(path, "missing required field %s for step %s", field.key, name)
+			}
+		case reflect.Bool:
+			if _, ok := value.(bool); !ok {
+				l.errorf(path+"."+field.key, "must be a boolean, got %s", jsonType(value))
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(config) {
+		if !known[key] {
+			l.warnf(path+"."+key, "unknown field for step %s", name)
+		}
+	}
+}
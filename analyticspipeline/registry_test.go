@@ -0,0 +1,123 @@
+package analyticspipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type extractConfig struct {
+	DistrictID string `config:"district_id,required"`
+	Collection string `config:"collection"`
+	DryRun     bool   `config:"dry_run"`
+}
+
+type loadConfig struct {
+	Table string `config:"table,required"`
+}
+
+func resetRegistry() {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry = map[string]reflect.Value{}
+}
+
+func TestRegisterWorker(t *testing.T) {
+	defer resetRegistry()
+
+	require.NoError(t, RegisterWorker("extract", &extractConfig{}))
+	require.NoError(t, RegisterWorker("extract", extractConfig{}), "re-registering the same config is allowed")
+	require.NoError(t, RegisterWorker("load", loadConfig{}))
+	require.Equal(t, []string{"extract", "load"}, RegisteredWorkers())
+	UnregisterWorker("load")
+	UnregisterWorker("report")
+	require.Equal(t, []string{"extract"}, RegisteredWorkers())
+
+	require.Error(t, RegisterWorker("extract", loadConfig{}), "step already registered")
+	require.Equal(t, errNoStepName, RegisterWorker("", loadConfig{}))
+	require.Error(t, RegisterWorker("report", "not a struct"))
+	require.Error(t, RegisterWorker("report", struct {
+		Limit int `config:"limit"`
+	}{}))
+	require.Error(t, RegisterWorker("report", struct {
+		Force bool `config:"force,required"`
+	}{}))
+}
+
+func TestValidatePayload(t *testing.T) {
+	defer resetRegistry()
+	require.NoError(t, RegisterWorker("extract", extractConfig{}))
+	require.NoError(t, RegisterWorker("load", loadConfig{}))
+
+	tests := []struct {
+		name     string
+		payload  string
+		warnOnly bool
+		want     []LintIssue
+	}{
+		{
+			name: "valid workflow",
+			payload: `{"current":{"step":"extract","district_id":"abc123","dry_run":true},
+				"remaining":[{"step":"load","table":"schools","retry":{"max_attempts":2}},{"step":"load","fan_out":[{"table":"a"},{"table":"b"}]}]}`,
+		},
+		{
+			name:    "missing required field in a later step",
+			payload: `{"current":{"step":"extract","district_id":"abc123"},"remaining":[{"step":"load"},{"step":"load","table":""}]}`,
+			want: []LintIssue{
+				{Severity: LintError, Path: "remaining[0]", Message: "missing required field table for step load"},
+				{Severity: LintError, Path: "remaining[1]", Message: "missing required field table for step load"},
+			},
+		},
+		{
+			name:    "wrong types and typos",
+			payload: `{"current":{"step":"extract","district_id":"abc123","dry_run":"yes","colection":"schools"},"remaining":[]}`,
+			want: []LintIssue{
+				{Severity: LintError, Path: "current.dry_run", Message: "must be a boolean, got string"},
+				{Severity: LintWarning, Path: "current.colection", Message: "unknown field for step extract"},
+			},
+		},
+		{
+			name:     "unknown keys are only warnings",
+			payload:  `{"current":{"step":"load","table":"schools","colection":"schools"}}`,
+			warnOnly: true,
+			want: []LintIssue{
+				{Severity: LintWarning, Path: "current.colection", Message: "unknown field for step load"},
+			},
+		},
+		{
+			name:    "unnamed and unregistered steps",
+			payload: `{"current":{"district_id":"abc123"},"remaining":[{"step":"report"}]}`,
+			want: []LintIssue{
+				{Severity: LintError, Path: "current", Message: `has no "step" naming its worker`},
+				{Severity: LintError, Path: "remaining[0]", Message: "no worker is registered for step report"},
+			},
+		},
+		{
+			name:    "fan out invocations are checked individually",
+			payload: `{"current":{"step":"load","fan_out":[{"table":"a"},{"limit":"5"}]}}`,
+			want: []LintIssue{
+				{Severity: LintError, Path: "current.fan_out[1]", Message: "missing required field table for step load"},
+				{Severity: LintWarning, Path: "current.fan_out[1].limit", Message: "unknown field for step load"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := ParsePayload([]byte(tt.payload))
+			require.NoError(t, err)
+
+			require.Equal(t, tt.want, ValidatePayloadIssues(payload))
+			err = ValidatePayload(payload)
+			if tt.want == nil || tt.warnOnly {
+				require.NoError(t, err)
+				return
+			}
+			require.IsType(t, &PayloadError{}, err)
+			require.Equal(t, tt.want, err.(*PayloadError).Issues)
+		})
+	}
+
+	require.Equal(t, errNilPayload, ValidatePayload(nil))
+	require.Equal(t, LintError, ValidatePayloadIssues(nil)[0].Severity)
+}
@@ -0,0 +1,118 @@
+package analyticspipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetryKey is the reserved key in a workflow step that holds its RetryPolicy.
+const RetryKey = "retry"
+
+// maxBackoffDoublings caps the exponential backoff so long retry chains cannot overflow.
+const maxBackoffDoublings = 16
+
+var (
+	errInvalidMaxAttempts = errors.New("retry policy max_attempts cannot be negative")
+	errInvalidExitCode    = errors.New("retry policy cannot list 0 as a retryable exit code")
+	errInvalidBackoff     = errors.New("retry policy backoff cannot be negative")
+)
+
+// RetryPolicy describes how a runner should retry a step that fails.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times the step may run, including the first attempt.
+	MaxAttempts int `json:"max_attempts"`
+	// Backoff is the delay before the first retry, in time.ParseDuration format. The delay
+	// doubles with every following retry.
+	Backoff string `json:"backoff,omitempty"`
+	// RetryableExitCodes limits retries to these exit codes. Any non-zero exit code is retried
+	// when it is empty.
+	RetryableExitCodes []int `json:"retryable_exit_codes,omitempty"`
+}
+
+// StepInfo describes the step that an AnalyticsWorker invocation is running.
+type StepInfo struct {
+	// Attempt is the 1-based attempt number of the current step.
+	Attempt int
+	// Retry is the retry policy the current step was scheduled with, or nil if it has none.
+	Retry *RetryPolicy
+}
+
+// RetryPolicyForStep returns the retry policy stored under RetryKey in a workflow step.
+// It returns nil if the step does not declare one.
+func RetryPolicyForStep(step map[string]interface{}) (*RetryPolicy, error) {
+	raw, ok := step[RetryKey]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var policy RetryPolicy
+	if err := json.Unmarshal(b, &policy); err != nil {
+		return nil, fmt.Errorf("invalid retry policy: %s", err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// validate determines if we have a usable retry policy
+func (p *RetryPolicy) validate() error {
+	if p.MaxAttempts < 0 {
+		return errInvalidMaxAttempts
+	}
+	if p.Backoff != "" {
+		backoff, err := time.ParseDuration(p.Backoff)
+		if err != nil {
+			return fmt.Errorf("invalid retry policy backoff: %s", err)
+		}
+		if backoff < 0 {
+			return errInvalidBackoff
+		}
+	}
+	for _, code := range p.RetryableExitCodes {
+		if code == 0 {
+			return errInvalidExitCode
+		}
+	}
+	return nil
+}
+
+// ShouldRetry reports whether a step that exited with exitCode on the given 1-based attempt
+// should be run again. A nil policy never retries.
+func (p *RetryPolicy) ShouldRetry(attempt, exitCode int) bool {
+	if p == nil || exitCode == 0 || attempt >= p.MaxAttempts {
+		return false
+	}
+	if len(p.RetryableExitCodes) == 0 {
+		return true
+	}
+	for _, code := range p.RetryableExitCodes {
+		if code == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns how long to wait after the given 1-based attempt fails before starting the next one.
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	if p == nil || p.Backoff == "" || attempt < 1 {
+		return 0
+	}
+	backoff, err := time.ParseDuration(p.Backoff)
+	if err != nil || backoff < 0 {
+		return 0
+	}
+	shift := attempt - 1
+	if shift > maxBackoffDoublings {
+		shift = maxBackoffDoublings
+	}
+	return backoff << uint(shift)
+}
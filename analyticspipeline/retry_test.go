@@ -0,0 +1,133 @@
+package analyticspipeline
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyForStep(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    map[string]interface{}
+		want    *RetryPolicy
+		wantErr bool
+	}{
+		{
+			name: "no policy",
+			step: map[string]interface{}{"district_id": "abc123"},
+		},
+		{
+			name: "full policy",
+			step: map[string]interface{}{
+				RetryKey: map[string]interface{}{
+					"max_attempts":         float64(5),
+					"backoff":              "30s",
+					"retryable_exit_codes": []interface{}{float64(2), float64(3)},
+				},
+			},
+			want: &RetryPolicy{MaxAttempts: 5, Backoff: "30s", RetryableExitCodes: []int{2, 3}},
+		},
+		{
+			name:    "policy is not an object",
+			step:    map[string]interface{}{RetryKey: "five times"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid backoff",
+			step:    map[string]interface{}{RetryKey: map[string]interface{}{"max_attempts": float64(2), "backoff": "soon"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative backoff",
+			step:    map[string]interface{}{RetryKey: map[string]interface{}{"max_attempts": float64(2), "backoff": "-1s"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative max attempts",
+			step:    map[string]interface{}{RetryKey: map[string]interface{}{"max_attempts": float64(-1)}},
+			wantErr: true,
+		},
+		{
+			name:    "zero exit code",
+			step:    map[string]interface{}{RetryKey: map[string]interface{}{"max_attempts": float64(2), "retryable_exit_codes": []interface{}{float64(0)}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RetryPolicyForStep(tt.step)
+			require.Equal(t, tt.wantErr, err != nil)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	var nilPolicy *RetryPolicy
+	assert.False(t, nilPolicy.ShouldRetry(1, 1))
+
+	anyCode := &RetryPolicy{MaxAttempts: 3}
+	assert.True(t, anyCode.ShouldRetry(1, 1))
+	assert.True(t, anyCode.ShouldRetry(2, 7))
+	assert.False(t, anyCode.ShouldRetry(3, 1), "attempts exhausted")
+	assert.False(t, anyCode.ShouldRetry(1, 0), "successful exit")
+
+	someCodes := &RetryPolicy{MaxAttempts: 3, RetryableExitCodes: []int{75}}
+	assert.True(t, someCodes.ShouldRetry(1, 75))
+	assert.False(t, someCodes.ShouldRetry(1, 1))
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	var nilPolicy *RetryPolicy
+	assert.Equal(t, time.Duration(0), nilPolicy.Delay(1))
+
+	p := &RetryPolicy{MaxAttempts: 5, Backoff: "10s"}
+	assert.Equal(t, 10*time.Second, p.Delay(1))
+	assert.Equal(t, 20*time.Second, p.Delay(2))
+	assert.Equal(t, 40*time.Second, p.Delay(3))
+	assert.Equal(t, 10*time.Second<<maxBackoffDoublings, p.Delay(100))
+
+	negative := &RetryPolicy{MaxAttempts: 5, Backoff: "-1s"}
+	assert.Equal(t, time.Duration(0), negative.Delay(1))
+}
+
+func TestAnalyticsWorkerStepInfo(t *testing.T) {
+	for _, spec := range []struct {
+		context string
+		args    []string
+		want    StepInfo
+	}{
+		{
+			context: "flags default to the first attempt",
+			args:    []string{"-district_id=abc123"},
+			want:    StepInfo{Attempt: 1},
+		},
+		{
+			context: "unwrapped json defaults to the first attempt",
+			args:    []string{`{"district_id":"abc123"}`},
+			want:    StepInfo{Attempt: 1},
+		},
+		{
+			context: "attempt and retry policy from the payload",
+			args:    []string{`{"current":{"district_id":"abc123","retry":{"max_attempts":5,"backoff":"1m"}},"remaining":[],"attempt":3}`},
+			want:    StepInfo{Attempt: 3, Retry: &RetryPolicy{MaxAttempts: 5, Backoff: "1m"}},
+		},
+	} {
+		os.Args = append([]string{"test"}, spec.args...)
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+		var config struct {
+			DistrictID string `config:"district_id,required"`
+		}
+		var info StepInfo
+		next, err := AnalyticsWorker(&config, WithStepInfo(&info))
+		assert.NoError(t, err, "Case '%s'", spec.context)
+		assert.Equal(t, spec.want, info, "Case '%s'", spec.context)
+		assert.Equal(t, 0, next.Attempt, "Case '%s'", spec.context)
+	}
+}
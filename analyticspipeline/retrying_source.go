@@ -0,0 +1,100 @@
+package analyticspipeline
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryingSource retries failed lookups of another LatencySource with jittered exponential
+// backoff, so that a single transient ALCS error doesn't declare a table stale. Note that the
+// checker's Timeout bounds all attempts together.
+type RetryingSource struct {
+	Source LatencySource
+	// MaxAttempts is the number of attempts, including the first. It defaults to 3.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles with each retry, up to MaxBackoff,
+	// and is jittered by up to half. It defaults to 100 milliseconds.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between attempts. It defaults to 5 seconds.
+	MaxBackoff time.Duration
+	// Retryable decides whether an error is worth retrying. By default every error is.
+	Retryable func(error) bool
+	// Breaker, if set, stops lookups while the source keeps failing.
+	Breaker *CircuitBreaker
+
+	// sleep waits between attempts. Tests replace it.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// TableLatency looks up a table's latency, retrying errors.
+func (s *RetryingSource) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	maxAttempts := s.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 3
+	}
+
+	for attempt := 1; ; attempt++ {
+		latency, err := s.attempt(ctx, table)
+		if err == nil || err == ErrBreakerOpen || attempt >= maxAttempts || ctx.Err() != nil {
+			return latency, err
+		}
+		if s.Retryable != nil && !s.Retryable(err) {
+			return nil, err
+		}
+		if err := s.wait(ctx, s.delay(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt makes a single lookup, if the breaker allows it.
+func (s *RetryingSource) attempt(ctx context.Context, table Table) (*TableLatency, error) {
+	if s.Breaker == nil {
+		return s.Source.TableLatency(ctx, table)
+	}
+	if err := s.Breaker.allow(); err != nil {
+		return nil, err
+	}
+	latency, err := s.Source.TableLatency(ctx, table)
+	if ctx.Err() == context.Canceled {
+		// a caller giving up isn't the source's fault
+		s.Breaker.abandon()
+	} else {
+		s.Breaker.record(err)
+	}
+	return latency, err
+}
+
+// delay returns how long to wait after a failed attempt.
+func (s *RetryingSource) delay(attempt int) time.Duration {
+	backoff, maxBackoff := s.Backoff, s.MaxBackoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Second
+	}
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (s *RetryingSource) wait(ctx context.Context, d time.Duration) error {
+	if s.sleep != nil {
+		return s.sleep(ctx, d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,131 @@
+package analyticspipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+var errTransient = errors.New("internal server error")
+
+// flakySource fails the first failures lookups.
+type flakySource struct {
+	failures int
+	calls    int
+}
+
+func (s *flakySource) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	s.calls++
+	if s.calls <= s.failures {
+		return nil, errTransient
+	}
+	return &TableLatency{Latency: floatPtr(1), Thresholds: &alcs.Thresholds{Refresh: "10h"}}, nil
+}
+
+// recordSleeps makes a RetryingSource record its delays instead of sleeping.
+func recordSleeps(s *RetryingSource) *[]time.Duration {
+	var delays []time.Duration
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return &delays
+}
+
+func TestRetryingSource(t *testing.T) {
+	for _, spec := range []struct {
+		description string
+		failures    int
+		retryable   func(error) bool
+		calls       int
+		wantErr     bool
+	}{
+		{description: "succeeds first time", failures: 0, calls: 1},
+		{description: "retries transient errors", failures: 2, calls: 3},
+		{description: "gives up after max attempts", failures: 5, calls: 3, wantErr: true},
+		{
+			description: "does not retry errors that are not retryable",
+			failures:    5,
+			retryable:   func(err error) bool { return false },
+			calls:       1,
+			wantErr:     true,
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			inner := &flakySource{failures: spec.failures}
+			source := &RetryingSource{Source: inner, Retryable: spec.retryable}
+			delays := recordSleeps(source)
+
+			latency, err := source.TableLatency(context.Background(), Table{})
+			require.Equal(t, spec.calls, inner.calls)
+			require.Len(t, *delays, spec.calls-1)
+			if spec.wantErr {
+				require.Equal(t, errTransient, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 1.0, *latency.Latency)
+		})
+	}
+}
+
+func TestRetryingSourceDelay(t *testing.T) {
+	source := &RetryingSource{Backoff: time.Second, MaxBackoff: 3 * time.Second}
+	for _, spec := range []struct {
+		attempt int
+		backoff time.Duration
+	}{
+		{attempt: 1, backoff: time.Second},
+		{attempt: 2, backoff: 2 * time.Second},
+		{attempt: 3, backoff: 3 * time.Second},
+		{attempt: 10, backoff: 3 * time.Second},
+	} {
+		for i := 0; i < 20; i++ {
+			delay := source.delay(spec.attempt)
+			require.True(t, delay >= spec.backoff/2 && delay <= spec.backoff, "attempt %d: %s", spec.attempt, delay)
+		}
+	}
+}
+
+func TestRetryingSourceBreaker(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	breaker := &CircuitBreaker{FailureThreshold: 2, Cooldown: time.Minute, Now: func() time.Time { return now }}
+	inner := &flakySource{failures: 100}
+	source := &RetryingSource{Source: inner, MaxAttempts: 5, Breaker: breaker}
+	recordSleeps(source)
+
+	// the breaker opens partway through the retries and stops them
+	_, err := source.TableLatency(context.Background(), Table{})
+	require.Equal(t, ErrBreakerOpen, err)
+	require.Equal(t, 2, inner.calls)
+
+	// the breaker is shared, so other lookups fail fast
+	other := &RetryingSource{Source: inner, Breaker: breaker}
+	_, err = other.TableLatency(context.Background(), Table{})
+	require.Equal(t, ErrBreakerOpen, err)
+	require.Equal(t, 2, inner.calls)
+
+	// once the source recovers, the trial call closes the breaker
+	inner.failures = 0
+	now = now.Add(time.Minute)
+	_, err = source.TableLatency(context.Background(), Table{})
+	require.NoError(t, err)
+	require.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestRetryingSourceContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	inner := &flakySource{failures: 100}
+	source := &RetryingSource{Source: inner, Backoff: time.Hour}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := source.TableLatency(ctx, Table{})
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, 1, inner.calls)
+}
@@ -0,0 +1,105 @@
+package analyticspipeline
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+
+	"github.com/Clever/analytics-util/metadata"
+)
+
+// ErrS3ObjectNotFound is returned by S3API.ObjectMetadata for an object that doesn't exist, e.g.
+// because it was deleted after it was listed.
+var ErrS3ObjectNotFound = errors.New("s3 object not found")
+
+// S3Object is an object listed by S3API.
+type S3Object struct {
+	Key          string
+	LastModified time.Time
+}
+
+// S3API is the subset of S3 that S3Source needs. Wrap an S3 client to implement it, or use an
+// in-memory fake in tests.
+type S3API interface {
+	// ListObjects returns every object in bucket whose key starts with prefix.
+	ListObjects(ctx context.Context, bucket, prefix string) ([]S3Object, error)
+	// ObjectMetadata returns an object's user-defined metadata, keyed the way
+	// metadata.NewS3MetaDataFromSDKMap reads it, e.g. x-amz-meta-schema-name. It returns an error
+	// wrapping ErrS3ObjectNotFound if the object doesn't exist.
+	ObjectMetadata(ctx context.Context, bucket, key string) (map[string]*string, error)
+}
+
+// S3Source looks up the latency of tables dumped to S3 and tagged with metadata.S3MetaData, for
+// sources ALCS doesn't track. A table's latency is the time since the newest object under Prefix
+// whose metadata names its schema and table was last modified. The table's database is ignored.
+type S3Source struct {
+	S3     S3API
+	Bucket string
+	Prefix string
+	// Thresholds apply to every table, in the ALCS format, e.g. {Refresh: "24h"}.
+	Thresholds *alcs.Thresholds
+	// Manifests loads the fields of wide tables whose metadata is in a manifest. Without it, those
+	// objects are skipped.
+	Manifests metadata.ManifestResolver
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// TableLatency finds the newest object for the table. Objects without valid metadata are skipped,
+// and a table without objects has no latency. Objects whose metadata can't be read, e.g. because
+// they were deleted after being listed, are skipped too. If no object of the table is found, the
+// first error other than ErrS3ObjectNotFound is returned.
+//
+// Every lookup lists Prefix, and reads the metadata of every object newer than the table's newest
+// one, plus its manifest if it has one. Keep Prefix narrow, and wrap the source in a CachedSource
+// when checking often.
+func (s *S3Source) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	objects, err := s.S3.ListObjects(ctx, s.Bucket, s.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(objects, func(i, j int) bool { return objects[i].LastModified.After(objects[j].LastModified) })
+
+	var headErr error
+	for _, object := range objects {
+		raw, err := s.S3.ObjectMetadata(ctx, s.Bucket, object.Key)
+		if errors.Is(err, ErrS3ObjectNotFound) {
+			continue
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if headErr == nil {
+				headErr = err
+			}
+			continue
+		}
+		// only parse, and read the manifests of, objects for this table
+		if !namesTable(raw, table) {
+			continue
+		}
+		if _, err := metadata.NewS3MetaDataFromSDKMapWithResolver(raw, s.Manifests); err != nil {
+			continue
+		}
+
+		now := time.Now
+		if s.Now != nil {
+			now = s.Now
+		}
+		latency := now().Sub(object.LastModified).Hours()
+		return &TableLatency{Latency: &latency, Thresholds: s.Thresholds}, nil
+	}
+	if headErr != nil {
+		return nil, headErr
+	}
+	return &TableLatency{Thresholds: s.Thresholds}, nil
+}
+
+// namesTable reports whether raw metadata is for table.
+func namesTable(raw map[string]*string, table Table) bool {
+	schema, name := raw[metadata.SchemaNameHeader], raw[metadata.TableNameHeader]
+	return schema != nil && name != nil && *schema == table.Schema && *name == table.Name
+}
@@ -0,0 +1,164 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Clever/analytics-util/metadata"
+)
+
+// fakeS3 is an in-memory bucket.
+type fakeS3 struct {
+	objects  []S3Object
+	metadata map[string]map[string]*string
+	err      error
+	headErrs map[string]error
+	heads    int
+}
+
+func (s *fakeS3) put(t *testing.T, key string, lastModified time.Time, schema, table string) {
+	meta, err := metadata.GenerateOrderedS3MetaData(schema, table, []metadata.Field{{Name: "id", Type: metadata.String}})
+	require.NoError(t, err)
+	s.objects = append(s.objects, S3Object{Key: key, LastModified: lastModified})
+	if s.metadata == nil {
+		s.metadata = map[string]map[string]*string{}
+	}
+	s.metadata[key] = meta
+}
+
+func (s *fakeS3) ListObjects(ctx context.Context, bucket, prefix string) ([]S3Object, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var objects []S3Object
+	for _, object := range s.objects {
+		if strings.HasPrefix(object.Key, prefix) {
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+func (s *fakeS3) ObjectMetadata(ctx context.Context, bucket, key string) (map[string]*string, error) {
+	s.heads++
+	if err := s.headErrs[key]; err != nil {
+		return nil, err
+	}
+	return s.metadata[key], nil
+}
+
+// manifestStore is an in-memory metadata.ManifestWriter and metadata.ManifestResolver that counts
+// reads.
+type manifestStore struct {
+	manifests map[string][]byte
+	reads     int
+}
+
+func (s *manifestStore) WriteManifest(key string, manifest []byte) error {
+	if s.manifests == nil {
+		s.manifests = map[string][]byte{}
+	}
+	s.manifests[key] = manifest
+	return nil
+}
+
+func (s *manifestStore) ReadManifest(key string) ([]byte, error) {
+	s.reads++
+	return s.manifests[key], nil
+}
+
+func TestS3Source(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	bucket := &fakeS3{}
+	bucket.put(t, "dumps/schools-1.csv", now.Add(-30*time.Hour), "public", "schools")
+	bucket.put(t, "dumps/schools-2.csv", now.Add(-6*time.Hour), "public", "schools")
+	bucket.put(t, "dumps/sections-1.csv", now.Add(-2*time.Hour), "public", "sections")
+	bucket.put(t, "other/students-1.csv", now.Add(-1*time.Hour), "public", "students")
+	bucket.objects = append(bucket.objects, S3Object{Key: "dumps/_SUCCESS", LastModified: now})
+
+	thresholds := &alcs.Thresholds{Refresh: "24h"}
+	source := &S3Source{S3: bucket, Bucket: "analytics", Prefix: "dumps/", Thresholds: thresholds, Now: func() time.Time { return now }}
+
+	latency, err := source.TableLatency(context.Background(), Table{Schema: "public", Name: "schools"})
+	require.NoError(t, err)
+	require.Equal(t, &TableLatency{Latency: floatPtr(6), Thresholds: thresholds}, latency)
+	// the newest objects are checked first, skipping objects without metadata
+	require.Equal(t, 3, bucket.heads)
+
+	latency, err = source.TableLatency(context.Background(), Table{Schema: "public", Name: "students"})
+	require.NoError(t, err)
+	require.Equal(t, &TableLatency{Thresholds: thresholds}, latency, "objects outside the prefix don't count")
+
+	checker := &FreshnessChecker{Logger: newRecordingLogger(), Source: source}
+	result := checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRedshiftFast, "public", "sections")
+	require.Equal(t, ReasonFresh, result.Reason)
+	require.Equal(t, 2.0, *result.Latency)
+
+	// wide tables' fields are in a manifest
+	manifests := &manifestStore{}
+	wide := make([]metadata.Field, 200)
+	for i := range wide {
+		wide[i] = metadata.Field{Name: fmt.Sprintf("column_%03d", i), Type: metadata.VarcharType(256)}
+	}
+	schema, name := "public", "events"
+	meta := &metadata.S3MetaData{SchemaName: &schema, TableName: &name, OrderedFields: wide}
+	raw, err := meta.ConvertToS3SDKFormatWithManifest(manifests, "dumps/events-1.csv.manifest")
+	require.NoError(t, err)
+	bucket.objects = append(bucket.objects, S3Object{Key: "dumps/events-1.csv", LastModified: now.Add(-90 * time.Minute)})
+	bucket.metadata["dumps/events-1.csv"] = raw
+
+	latency, err = source.TableLatency(context.Background(), Table{Schema: "public", Name: "events"})
+	require.NoError(t, err)
+	require.Nil(t, latency.Latency, "objects with manifests are skipped without a resolver")
+	source.Manifests = manifests
+	latency, err = source.TableLatency(context.Background(), Table{Schema: "public", Name: "events"})
+	require.NoError(t, err)
+	require.Equal(t, 1.5, *latency.Latency)
+	require.Equal(t, 1, manifests.reads)
+
+	// only the manifests of the table's own objects are read
+	latency, err = source.TableLatency(context.Background(), Table{Schema: "public", Name: "sections"})
+	require.NoError(t, err)
+	require.Equal(t, 2.0, *latency.Latency)
+	require.Equal(t, 1, manifests.reads)
+
+	bucket.err = fmt.Errorf("access denied")
+	result = checker.CheckTableFreshness(context.Background(), alcs.AnalyticsDatabaseRedshiftFast, "public", "sections")
+	require.Equal(t, ReasonError, result.Reason)
+}
+
+func TestS3SourceObjectMetadataErrors(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	bucket := &fakeS3{}
+	bucket.put(t, "dumps/schools-1.csv", now.Add(-6*time.Hour), "public", "schools")
+	bucket.put(t, "dumps/schools-2.csv", now.Add(-2*time.Hour), "public", "schools")
+	bucket.put(t, "dumps/sections-1.csv", now.Add(-1*time.Hour), "public", "sections")
+	source := &S3Source{S3: bucket, Bucket: "analytics", Prefix: "dumps/", Now: func() time.Time { return now }}
+	schools := Table{Schema: "public", Name: "schools"}
+
+	// an object deleted after it was listed is skipped
+	bucket.headErrs = map[string]error{"dumps/schools-2.csv": fmt.Errorf("head: %w", ErrS3ObjectNotFound)}
+	latency, err := source.TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+	require.Equal(t, 6.0, *latency.Latency)
+
+	// as are other errors, as long as an object of the table is found
+	bucket.headErrs = map[string]error{"dumps/sections-1.csv": fmt.Errorf("access denied")}
+	latency, err = source.TableLatency(context.Background(), schools)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, *latency.Latency)
+
+	// otherwise, the error is returned
+	_, err = source.TableLatency(context.Background(), Table{Schema: "public", Name: "sections"})
+	require.EqualError(t, err, "access denied")
+	bucket.headErrs = map[string]error{"dumps/sections-1.csv": ErrS3ObjectNotFound}
+	latency, err = source.TableLatency(context.Background(), Table{Schema: "public", Name: "sections"})
+	require.NoError(t, err)
+	require.Nil(t, latency.Latency, "a table whose only object was deleted has no latency")
+}
@@ -0,0 +1,144 @@
+package analyticspipeline
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// jsonSchemaDraft is the JSON Schema version generated schemas conform to.
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema is the subset of a JSON Schema document needed to describe a worker config.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Const                interface{}            `json:"const,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	MinItems             int                    `json:"minItems,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+}
+
+// ConfigSchema returns a JSON Schema describing the workflow steps a config struct accepts. Keys,
+// types and required fields come from the `config` tags, descriptions from `description` tags,
+// and defaults from the non-zero values of configStruct.
+func ConfigSchema(configStruct interface{}) (*JSONSchema, error) {
+	if configStruct == nil {
+		return nil, errStructOnly
+	}
+	fields, err := configFields(reflect.TypeOf(configStruct))
+	if err != nil {
+		return nil, err
+	}
+	return configSchema(fields, reflect.Indirect(reflect.ValueOf(configStruct))), nil
+}
+
+// WorkerSchema returns the JSON Schema of the config registered for step with RegisterWorker.
+func WorkerSchema(step string) (*JSONSchema, error) {
+	config, ok := registeredConfig(step)
+	if !ok {
+		return nil, fmt.Errorf("no worker is registered for step %s", step)
+	}
+	fields, err := configFields(config.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	schema := configSchema(fields, config)
+	schema.Title = step
+	schema.Properties[StepKey].Const = step
+	schema.Required = []string{StepKey}
+	return schema, nil
+}
+
+func configSchema(fields []configField, defaults reflect.Value) *JSONSchema {
+	schema := &JSONSchema{
+		Schema:               jsonSchemaDraft,
+		Type:                 "object",
+		Properties:           configProperties(fields, defaults),
+		AdditionalProperties: boolPtr(false),
+	}
+	required := []string{}
+	for _, field := range fields {
+		if field.required && defaults.Field(field.index).String() == "" {
+			required = append(required, field.key)
+		}
+	}
+	if len(required) > 0 {
+		// a fanned out step may leave required fields to its fan_out entries
+		schema.AnyOf = []*JSONSchema{{Required: required}, {Required: []string{FanOutKey}}}
+	}
+
+	// reserved keys that any step may carry
+	schema.Properties[StepKey] = &JSONSchema{Type: "string", Description: "the name of the worker that runs the step"}
+	schema.Properties[RetryKey] = retryPolicySchema()
+	schema.Properties[FanOutKey] = &JSONSchema{
+		Description: "runs the step once per entry, with the entry's values layered over the step's",
+		Type:        "array",
+		MinItems:    1,
+		Items: &JSONSchema{
+			Type:                 "object",
+			Properties:           configProperties(fields, defaults),
+			AdditionalProperties: boolPtr(false),
+		},
+	}
+	return schema
+}
+
+func configProperties(fields []configField, defaults reflect.Value) map[string]*JSONSchema {
+	properties := map[string]*JSONSchema{}
+	for _, field := range fields {
+		property := &JSONSchema{Description: field.description}
+		value := defaults.Field(field.index)
+		switch field.kind {
+		case reflect.String:
+			property.Type = "string"
+			if value.String() != "" {
+				property.Default = value.String()
+			}
+		case reflect.Bool:
+			property.Type = "boolean"
+			if value.Bool() {
+				property.Default = true
+			}
+		}
+		properties[field.key] = property
+	}
+	return properties
+}
+
+func retryPolicySchema() *JSONSchema {
+	zero, one := 0, 1
+	return &JSONSchema{
+		Description: "how a runner should retry the step if it fails",
+		Type:        "object",
+		Properties: map[string]*JSONSchema{
+			"max_attempts": {
+				Description: "the total number of runs allowed, including the first",
+				Type:        "integer",
+				Minimum:     &zero,
+			},
+			"backoff": {
+				Description: "the delay before the first retry, doubled for every following retry",
+				Type:        "string",
+			},
+			"retryable_exit_codes": {
+				Description: "the exit codes worth retrying. Every non-zero exit code is retried when omitted",
+				Type:        "array",
+				Items:       &JSONSchema{Type: "integer", Minimum: &one},
+			},
+		},
+		Required:             []string{"max_attempts"},
+		AdditionalProperties: boolPtr(false),
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
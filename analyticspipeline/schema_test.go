@@ -0,0 +1,63 @@
+package analyticspipeline
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type describedConfig struct {
+	DistrictID string `config:"district_id,required" description:"the district to extract"`
+	Collection string `config:"collection"`
+	DryRun     bool   `config:"dry_run" description:"log instead of writing"`
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema, err := ConfigSchema(&describedConfig{Collection: "schools", DryRun: true})
+	require.NoError(t, err)
+
+	require.Equal(t, jsonSchemaDraft, schema.Schema)
+	require.Equal(t, "object", schema.Type)
+	require.Equal(t, &JSONSchema{Type: "string", Description: "the district to extract"}, schema.Properties["district_id"])
+	require.Equal(t, &JSONSchema{Type: "string", Default: "schools"}, schema.Properties["collection"])
+	require.Equal(t, &JSONSchema{Type: "boolean", Default: true, Description: "log instead of writing"}, schema.Properties["dry_run"])
+	require.Equal(t, []*JSONSchema{{Required: []string{"district_id"}}, {Required: []string{FanOutKey}}}, schema.AnyOf)
+	require.Empty(t, schema.Required)
+	require.False(t, *schema.AdditionalProperties)
+
+	for _, reserved := range []string{StepKey, RetryKey, FanOutKey} {
+		require.Contains(t, schema.Properties, reserved)
+	}
+	require.Equal(t, schema.Properties["collection"], schema.Properties[FanOutKey].Items.Properties["collection"])
+
+	_, err = ConfigSchema("not a struct")
+	require.Equal(t, errStructOnly, err)
+	_, err = ConfigSchema(nil)
+	require.Equal(t, errStructOnly, err)
+}
+
+func TestConfigSchemaRequiredWithDefault(t *testing.T) {
+	schema, err := ConfigSchema(describedConfig{DistrictID: "abc123"})
+	require.NoError(t, err)
+	require.Empty(t, schema.AnyOf, "a required field with a default can be omitted")
+	require.Equal(t, "abc123", schema.Properties["district_id"].Default)
+}
+
+func TestWorkerSchema(t *testing.T) {
+	defer resetRegistry()
+	require.NoError(t, RegisterWorker("extract", describedConfig{}))
+
+	schema, err := WorkerSchema("extract")
+	require.NoError(t, err)
+	require.Equal(t, "extract", schema.Title)
+	require.Equal(t, "extract", schema.Properties[StepKey].Const)
+	require.Equal(t, []string{StepKey}, schema.Required)
+
+	b, err := json.Marshal(schema.Properties[StepKey])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":"string","const":"extract","description":"the name of the worker that runs the step"}`, string(b))
+
+	_, err = WorkerSchema("load")
+	require.EqualError(t, err, "no worker is registered for step load")
+}
@@ -0,0 +1,60 @@
+package analyticspipeline
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	errNoFreshnessTarget  = errors.New("skipping fresh data needs a config struct that implements FreshnessTarget")
+	errNoFreshnessChecker = errors.New("skipping fresh data needs a FreshnessChecker")
+)
+
+// FreshnessTarget is implemented by config structs of workers that can be skipped while the table
+// they build is fresh.
+type FreshnessTarget interface {
+	// FreshnessTable returns the table the worker builds, or false if the worker should always run,
+	// e.g. for a forced rebuild.
+	FreshnessTable() (Table, bool)
+}
+
+// WithSkipIfFresh checks, once the config is parsed, whether the table the config's
+// FreshnessTarget names is fresh, and sets skipped to true if it is. AnalyticsWorker still returns
+// the payload for the next step, so a skipped worker should print it and exit without doing any
+// work, and the rest of the workflow proceeds:
+//
+//	var skipped bool
+//	next, err := analyticspipeline.AnalyticsWorker(&config, analyticspipeline.WithSkipIfFresh(checker, &skipped))
+//	if err != nil {
+//		log.Fatal(err)
+//	} else if skipped {
+//		analyticspipeline.PrintPayload(next)
+//		return
+//	}
+func WithSkipIfFresh(checker *FreshnessChecker, skipped *bool) WorkerOption {
+	return func(o *workerOptions) {
+		o.freshnessChecker = checker
+		o.skipped = skipped
+	}
+}
+
+// skipIfFresh runs the freshness check requested by WithSkipIfFresh.
+func (o *workerOptions) skipIfFresh(configStruct interface{}) error {
+	if o.skipped == nil {
+		return nil
+	}
+	*o.skipped = false
+	if o.freshnessChecker == nil {
+		return errNoFreshnessChecker
+	}
+	target, ok := configStruct.(FreshnessTarget)
+	if !ok {
+		return errNoFreshnessTarget
+	}
+	table, ok := target.FreshnessTable()
+	if !ok {
+		return nil
+	}
+	*o.skipped = o.freshnessChecker.IsTableDataFresh(context.Background(), table.Database, table.Schema, table.Name)
+	return nil
+}
@@ -0,0 +1,68 @@
+package analyticspipeline
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type debouncedConfig struct {
+	Schema string `config:"schema,required"`
+	Table  string `config:"table,required"`
+	Force  bool   `config:"force"`
+}
+
+func (c *debouncedConfig) FreshnessTable() (Table, bool) {
+	return Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: c.Schema, Name: c.Table}, !c.Force
+}
+
+func TestAnalyticsWorkerSkipIfFresh(t *testing.T) {
+	fresh := staticSource{latency: &TableLatency{Latency: floatPtr(5), Thresholds: &alcs.Thresholds{Refresh: "10h"}}}
+	stale := staticSource{latency: &TableLatency{Latency: floatPtr(15), Thresholds: &alcs.Thresholds{Refresh: "10h"}}}
+	payload := `{"current":{"schema":"public","table":"schools"},"remaining":[{"step":"load"}]}`
+	forced := `{"current":{"schema":"public","table":"schools","force":true},"remaining":[{"step":"load"}]}`
+
+	for _, spec := range []struct {
+		context string
+		arg     string
+		source  LatencySource
+		skipped bool
+		titles  []string
+	}{
+		{context: "fresh data is skipped", arg: payload, source: fresh, skipped: true, titles: []string{"latency-debounce-fresh"}},
+		{context: "stale data runs", arg: payload, source: stale, skipped: false, titles: []string{"latency-debounce-stale"}},
+		{context: "the config can opt out of the check", arg: forced, source: fresh, skipped: false, titles: []string{}},
+	} {
+		os.Args = []string{"test", spec.arg}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+		logger := newRecordingLogger()
+		checker := &FreshnessChecker{Logger: logger, Source: spec.source}
+		var config debouncedConfig
+		skipped := !spec.skipped
+		next, err := AnalyticsWorker(&config, WithSkipIfFresh(checker, &skipped))
+		assert.NoError(t, err, "Case '%s'", spec.context)
+		assert.Equal(t, spec.skipped, skipped, "Case '%s'", spec.context)
+		assert.Equal(t, map[string]interface{}{"step": "load"}, next.Current, "Case '%s'", spec.context)
+		assert.Equal(t, spec.titles, logger.Titles(), "Case '%s'", spec.context)
+	}
+}
+
+func TestAnalyticsWorkerSkipIfFreshErrors(t *testing.T) {
+	os.Args = []string{"test", `{"district_id":"abc123"}`}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	var config struct {
+		DistrictID string `config:"district_id,required"`
+	}
+	var skipped bool
+	_, err := AnalyticsWorker(&config, WithSkipIfFresh(&FreshnessChecker{Logger: newRecordingLogger()}, &skipped))
+	assert.Equal(t, errNoFreshnessTarget, err)
+
+	os.Args = []string{"test", `{"schema":"public","table":"schools"}`}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	_, err = AnalyticsWorker(&debouncedConfig{}, WithSkipIfFresh(nil, &skipped))
+	assert.Equal(t, errNoFreshnessChecker, err)
+}
@@ -0,0 +1,119 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	alcsHelpers "github.com/Clever/analytics-latency-config-service/helpers"
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+)
+
+// ThresholdTiers are the ALCS threshold tiers, most severe first.
+var ThresholdTiers = []alcs.ThresholdTier{
+	alcs.ThresholdTierCritical,
+	alcs.ThresholdTierMajor,
+	alcs.ThresholdTierMinor,
+	alcs.ThresholdTierRefresh,
+}
+
+// TierResult is the outcome of checking a table against every threshold tier.
+type TierResult struct {
+	// Latency is the table's latency in hours, or nil if it is unknown.
+	Latency    *float64
+	Thresholds *alcs.Thresholds
+	// Crossed are the tiers the latency crosses, most severe first.
+	Crossed []alcs.ThresholdTier
+	// Err is set if the latency could not be looked up or a threshold could not be parsed.
+	Err error
+}
+
+// Crosses reports whether the table's latency crosses tier.
+func (r TierResult) Crosses(tier alcs.ThresholdTier) bool {
+	for _, crossed := range r.Crossed {
+		if crossed == tier {
+			return true
+		}
+	}
+	return false
+}
+
+// MostSevere returns the most severe tier the table's latency crosses, or false if it crosses none.
+func (r TierResult) MostSevere() (alcs.ThresholdTier, bool) {
+	if len(r.Crossed) == 0 {
+		return "", false
+	}
+	return r.Crossed[0], true
+}
+
+// CrossedTiers returns the tiers whose thresholds latency crosses, most severe first. Tiers
+// without a threshold are never crossed.
+func CrossedTiers(latency float64, thresholds *alcs.Thresholds) ([]alcs.ThresholdTier, error) {
+	crossed := []alcs.ThresholdTier{}
+	if thresholds == nil {
+		return crossed, nil
+	}
+	for _, tier := range ThresholdTiers {
+		if threshold := tierThreshold(thresholds, tier); threshold == "" || threshold == alcsHelpers.NoLatencyAlert {
+			continue
+		}
+		isCrossed, _, err := alcsHelpers.CheckThresholdCrossed(latency, thresholds, tier)
+		if err != nil {
+			return nil, fmt.Errorf("%s threshold: %s", tier, err)
+		}
+		if isCrossed {
+			crossed = append(crossed, tier)
+		}
+	}
+	return crossed, nil
+}
+
+// tierThreshold returns the configured threshold of a tier.
+func tierThreshold(thresholds *alcs.Thresholds, tier alcs.ThresholdTier) string {
+	switch tier {
+	case alcs.ThresholdTierCritical:
+		return thresholds.Critical
+	case alcs.ThresholdTierMajor:
+		return thresholds.Major
+	case alcs.ThresholdTierMinor:
+		return thresholds.Minor
+	default:
+		return thresholds.Refresh
+	}
+}
+
+// CheckTiers looks up a table's latency and reports which threshold tiers it crosses, so that
+// workers can pick how to load it based on how stale it is, e.g. an incremental load when only
+// Refresh is crossed and a full rebuild past Major. The outcome is logged as latency-tiers.
+func (c *FreshnessChecker) CheckTiers(ctx context.Context, database alcs.AnalyticsDatabase, schema, table string) TierResult {
+	logPayload := kvlogger.M{
+		"database": database,
+		"schema":   schema,
+		"table":    table,
+	}
+
+	var result TierResult
+	latency, err := c.getTableLatency(ctx, Table{Database: database, Schema: schema, Name: table})
+	if err != nil {
+		result.Err = err
+	} else {
+		result.Latency = latency.Latency
+		result.Thresholds = latency.Thresholds
+		result.Crossed = []alcs.ThresholdTier{}
+		if latency.Latency != nil {
+			result.Crossed, result.Err = CrossedTiers(*latency.Latency, latency.Thresholds)
+		}
+	}
+
+	if result.Latency != nil {
+		logPayload["latency"] = *result.Latency
+	} else {
+		logPayload["latency"] = "not found"
+	}
+	if result.Err != nil {
+		logPayload["error"] = result.Err
+	}
+	logPayload["crossed"] = result.Crossed
+	c.Logger.InfoD("latency-tiers", logPayload)
+	return result
+}
@@ -0,0 +1,112 @@
+package analyticspipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	alcsHelpers "github.com/Clever/analytics-latency-config-service/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrossedTiers(t *testing.T) {
+	thresholds := &alcs.Thresholds{Critical: "48h", Major: "24h", Minor: "12h", Refresh: "6h"}
+	for _, spec := range []struct {
+		description string
+		latency     float64
+		thresholds  *alcs.Thresholds
+		want        []alcs.ThresholdTier
+		wantErr     string
+	}{
+		{
+			description: "crosses none",
+			latency:     1,
+			thresholds:  thresholds,
+			want:        []alcs.ThresholdTier{},
+		},
+		{
+			description: "crosses refresh",
+			latency:     8,
+			thresholds:  thresholds,
+			want:        []alcs.ThresholdTier{alcs.ThresholdTierRefresh},
+		},
+		{
+			description: "crosses every tier, most severe first",
+			latency:     50,
+			thresholds:  thresholds,
+			want:        ThresholdTiers,
+		},
+		{
+			description: "tiers without a threshold are never crossed",
+			latency:     50,
+			thresholds:  &alcs.Thresholds{Critical: alcsHelpers.NoLatencyAlert, Minor: "12h", Refresh: "6h"},
+			want:        []alcs.ThresholdTier{alcs.ThresholdTierMinor, alcs.ThresholdTierRefresh},
+		},
+		{
+			description: "no thresholds",
+			latency:     50,
+			want:        []alcs.ThresholdTier{},
+		},
+		{
+			description: "invalid threshold",
+			latency:     50,
+			thresholds:  &alcs.Thresholds{Major: "1j"},
+			wantErr:     "Major threshold: ",
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			crossed, err := CrossedTiers(spec.latency, spec.thresholds)
+			if spec.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), spec.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, spec.want, crossed)
+		})
+	}
+}
+
+func TestCheckTiers(t *testing.T) {
+	thresholds := &alcs.Thresholds{Critical: "48h", Major: "24h", Minor: "12h", Refresh: "6h"}
+	for _, spec := range []struct {
+		description string
+		source      staticSource
+		crossed     []alcs.ThresholdTier
+		mostSevere  alcs.ThresholdTier
+		wantErr     bool
+	}{
+		{
+			description: "stale past major",
+			source:      staticSource{latency: &TableLatency{Latency: floatPtr(30), Thresholds: thresholds}},
+			crossed:     []alcs.ThresholdTier{alcs.ThresholdTierMajor, alcs.ThresholdTierMinor, alcs.ThresholdTierRefresh},
+			mostSevere:  alcs.ThresholdTierMajor,
+		},
+		{
+			description: "no latency",
+			source:      staticSource{latency: &TableLatency{Thresholds: thresholds}},
+			crossed:     []alcs.ThresholdTier{},
+		},
+		{
+			description: "error",
+			source:      staticSource{err: fmt.Errorf("connection error")},
+			wantErr:     true,
+		},
+	} {
+		t.Run(spec.description, func(t *testing.T) {
+			logger := newRecordingLogger()
+			checker := &FreshnessChecker{Logger: logger, Source: spec.source}
+			result := checker.CheckTiers(context.Background(), alcs.AnalyticsDatabaseRedshiftFast, "public", "schools")
+
+			require.Equal(t, spec.crossed, result.Crossed)
+			require.Equal(t, spec.wantErr, result.Err != nil)
+			mostSevere, ok := result.MostSevere()
+			require.Equal(t, spec.mostSevere, mostSevere)
+			require.Equal(t, spec.mostSevere != "", ok)
+			require.Equal(t, spec.mostSevere != "", result.Crosses(alcs.ThresholdTierRefresh))
+			require.False(t, result.Crosses(alcs.ThresholdTierCritical))
+			require.Equal(t, []string{"latency-tiers"}, logger.Titles())
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package analyticspipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+)
+
+var errInvalidPollInterval = errors.New("poll interval must be positive")
+
+// StaleTablesError is returned by WaitUntilFresh when it gives up on tables that are still stale.
+type StaleTablesError struct {
+	// Tables are the tables that were still stale, sorted.
+	Tables []Table
+	// Results are the last results for those tables.
+	Results map[Table]FreshnessResult
+	// Err is why it gave up: context.DeadlineExceeded on a timeout, or context.Canceled.
+	Err error
+}
+
+func (e *StaleTablesError) Error() string {
+	names := make([]string, len(e.Tables))
+	for i, table := range e.Tables {
+		names[i] = table.String()
+	}
+	return fmt.Sprintf("%s while waiting for tables to be fresh, still stale: %s", e.Err, strings.Join(names, ", "))
+}
+
+// Unwrap returns why WaitUntilFresh gave up.
+func (e *StaleTablesError) Unwrap() error {
+	return e.Err
+}
+
+// WaitUntilFresh polls the checker's source every pollInterval until every table is fresh, so
+// that a downstream job can wait for its upstream tables to be refreshed. It gives up once timeout
+// passes or ctx is done, returning a *StaleTablesError listing the tables that are still stale. A
+// timeout of zero means it only gives up when ctx is done. pollInterval must be positive.
+//
+// A table is only fresh once its latency is under its refresh threshold. The checker's Policy is
+// ignored, so that errors keep waiting rather than failing closed, and a table without a refresh
+// threshold is never fresh. Polls don't emit the checker's Metrics. The outcome is logged as
+// latency-wait-fresh or latency-wait-stale.
+func (c *FreshnessChecker) WaitUntilFresh(ctx context.Context, tables []Table, pollInterval, timeout time.Duration) error {
+	if pollInterval <= 0 {
+		return errInvalidPollInterval
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	pending := tables
+	polls := 0
+	for {
+		polls++
+		results := c.checkAll(ctx, pending, DefaultBatchConcurrency, c.observe)
+		stale := []Table{}
+		for table, result := range results {
+			if result.Reason != ReasonFresh {
+				stale = append(stale, table)
+			}
+		}
+		if len(stale) == 0 {
+			c.Logger.InfoD("latency-wait-fresh", kvlogger.M{
+				"tables":  len(tables),
+				"polls":   polls,
+				"elapsed": time.Since(start).String(),
+			})
+			return nil
+		}
+		pending = stale
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-timer.C:
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+		}
+
+		sort.Slice(stale, func(i, j int) bool { return stale[i].String() < stale[j].String() })
+		err := &StaleTablesError{Tables: stale, Results: map[Table]FreshnessResult{}, Err: ctx.Err()}
+		names := make([]string, len(stale))
+		for i, table := range stale {
+			err.Results[table] = results[table]
+			names[i] = table.String()
+		}
+		c.Logger.InfoD("latency-wait-stale", kvlogger.M{
+			"tables":  len(tables),
+			"polls":   polls,
+			"elapsed": time.Since(start).String(),
+			"stale":   names,
+			"error":   ctx.Err(),
+		})
+		return err
+	}
+}
@@ -0,0 +1,113 @@
+package analyticspipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	"github.com/stretchr/testify/require"
+)
+
+// refreshingSource reports a table as stale until it has been looked up freshAfter[table] times.
+type refreshingSource struct {
+	lock       sync.Mutex
+	freshAfter map[string]int
+	calls      map[string]int
+}
+
+func (s *refreshingSource) TableLatency(ctx context.Context, table Table) (*TableLatency, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.calls == nil {
+		s.calls = map[string]int{}
+	}
+	s.calls[table.Name]++
+	latency := 15.0
+	if s.calls[table.Name] >= s.freshAfter[table.Name] {
+		latency = 5
+	}
+	return &TableLatency{Latency: &latency, Thresholds: &alcs.Thresholds{Refresh: "10h"}}, nil
+}
+
+func TestWaitUntilFresh(t *testing.T) {
+	schools := Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "schools"}
+	sections := Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "sections"}
+	source := &refreshingSource{freshAfter: map[string]int{"schools": 1, "sections": 3}}
+	logger := newRecordingLogger()
+	checker := &FreshnessChecker{Logger: logger, Source: source}
+
+	require.NoError(t, checker.WaitUntilFresh(context.Background(), []Table{schools, sections}, time.Millisecond, time.Second))
+	// fresh tables aren't checked again
+	require.Equal(t, map[string]int{"schools": 1, "sections": 3}, source.calls)
+	require.Equal(t, []string{"latency-wait-fresh"}, logger.Titles())
+	require.Equal(t, 3, logger.data[0]["polls"])
+}
+
+func TestWaitUntilFreshTimeout(t *testing.T) {
+	schools := Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "schools"}
+	sections := Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "sections"}
+	teachers := Table{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Name: "teachers"}
+	source := &refreshingSource{freshAfter: map[string]int{"schools": 1, "sections": 1000, "teachers": 1000}}
+	logger := newRecordingLogger()
+	checker := &FreshnessChecker{Logger: logger, Source: source}
+
+	err := checker.WaitUntilFresh(context.Background(), []Table{teachers, schools, sections}, 5*time.Millisecond, 30*time.Millisecond)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	staleErr, ok := err.(*StaleTablesError)
+	require.True(t, ok)
+	require.Equal(t, []Table{sections, teachers}, staleErr.Tables)
+	require.Equal(t, ReasonStale, staleErr.Results[sections].Reason)
+	require.Contains(t, err.Error(), "still stale: "+sections.String()+", "+teachers.String())
+	require.Equal(t, []string{"latency-wait-stale"}, logger.Titles())
+}
+
+func TestWaitUntilFreshCanceled(t *testing.T) {
+	source := &refreshingSource{freshAfter: map[string]int{"schools": 1000}}
+	checker := &FreshnessChecker{Logger: newRecordingLogger(), Source: source}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err := checker.WaitUntilFresh(ctx, []Table{{Schema: "public", Name: "schools"}}, time.Hour, 0)
+	require.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestWaitUntilFreshInvalidPollInterval(t *testing.T) {
+	source := &refreshingSource{freshAfter: map[string]int{"schools": 1000}}
+	checker := &FreshnessChecker{Logger: newRecordingLogger(), Source: source}
+
+	for _, pollInterval := range []time.Duration{0, -time.Second} {
+		err := checker.WaitUntilFresh(context.Background(), []Table{{Schema: "public", Name: "schools"}}, pollInterval, 0)
+		require.Equal(t, errInvalidPollInterval, err)
+	}
+	require.Empty(t, source.calls)
+}
+
+func TestWaitUntilFreshIgnoresPolicy(t *testing.T) {
+	schools := Table{Schema: "public", Name: "schools"}
+	for _, policy := range []*FailurePolicy{{Mode: FailClosed}, {Mode: FailClosedAfterN, N: 1}} {
+		t.Run(policy.Mode.String(), func(t *testing.T) {
+			logger := &metricsLogger{recordingLogger: newRecordingLogger()}
+			checker := &FreshnessChecker{
+				Logger:  logger,
+				Source:  staticSource{err: fmt.Errorf("connection error")},
+				Policy:  policy,
+				Metrics: &FreshnessMetrics{},
+			}
+
+			err := checker.WaitUntilFresh(context.Background(), []Table{schools}, time.Millisecond, 30*time.Millisecond)
+			require.True(t, errors.Is(err, context.DeadlineExceeded), "an erroring source is never fresh")
+			require.Equal(t, ReasonError, err.(*StaleTablesError).Results[schools].Reason)
+			require.Empty(t, logger.metrics)
+			require.Equal(t, 0, policy.consecutive, "polls don't count towards the policy")
+		})
+	}
+}
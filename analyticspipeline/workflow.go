@@ -0,0 +1,57 @@
+package analyticspipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reserved keys in a workflow step. Config structs should not use them as config tags.
+const (
+	// StepKey names the worker that runs a step.
+	StepKey = "step"
+	// FanOutKey holds a list of objects. The step runs once per object, with the object's
+	// values layered over the rest of the step.
+	FanOutKey = "fan_out"
+)
+
+var errInvalidFanOut = errors.New("fan_out must be a list of objects")
+
+// StepName returns the name of the worker that runs a workflow step, or "" if it has none.
+func StepName(step map[string]interface{}) string {
+	name, _ := step[StepKey].(string)
+	return name
+}
+
+// ExpandFanOut returns the configs of every invocation of a workflow step. A step without
+// FanOutKey has a single invocation: the step itself.
+func ExpandFanOut(step map[string]interface{}) ([]map[string]interface{}, error) {
+	raw, ok := step[FanOutKey]
+	if !ok || raw == nil {
+		return []map[string]interface{}{step}, nil
+	}
+
+	overrides, ok := raw.([]interface{})
+	if !ok || len(overrides) == 0 {
+		return nil, errInvalidFanOut
+	}
+
+	invocations := make([]map[string]interface{}, 0, len(overrides))
+	for i, o := range overrides {
+		override, ok := o.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("fan_out entry %d is not an object", i)
+		}
+
+		invocation := map[string]interface{}{}
+		for k, v := range step {
+			if k != FanOutKey {
+				invocation[k] = v
+			}
+		}
+		for k, v := range override {
+			invocation[k] = v
+		}
+		invocations = append(invocations, invocation)
+	}
+	return invocations, nil
+}
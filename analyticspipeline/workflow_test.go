@@ -0,0 +1,62 @@
+package analyticspipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepName(t *testing.T) {
+	require.Equal(t, "extract", StepName(map[string]interface{}{StepKey: "extract"}))
+	require.Equal(t, "", StepName(map[string]interface{}{"district_id": "abc123"}))
+	require.Equal(t, "", StepName(map[string]interface{}{StepKey: 5}))
+}
+
+func TestExpandFanOut(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    map[string]interface{}
+		want    []map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "no fan out",
+			step: map[string]interface{}{StepKey: "load", "table": "schools"},
+			want: []map[string]interface{}{{StepKey: "load", "table": "schools"}},
+		},
+		{
+			name: "fan out overrides step values",
+			step: map[string]interface{}{
+				StepKey:   "load",
+				"table":   "schools",
+				FanOutKey: []interface{}{map[string]interface{}{"table": "students"}, map[string]interface{}{"shard": "1"}},
+			},
+			want: []map[string]interface{}{
+				{StepKey: "load", "table": "students"},
+				{StepKey: "load", "table": "schools", "shard": "1"},
+			},
+		},
+		{
+			name:    "fan out is not a list",
+			step:    map[string]interface{}{FanOutKey: "schools,students"},
+			wantErr: true,
+		},
+		{
+			name:    "fan out is empty",
+			step:    map[string]interface{}{FanOutKey: []interface{}{}},
+			wantErr: true,
+		},
+		{
+			name:    "fan out entry is not an object",
+			step:    map[string]interface{}{FanOutKey: []interface{}{"schools"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandFanOut(tt.step)
+			require.Equal(t, tt.wantErr, err != nil)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
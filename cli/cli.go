@@ -0,0 +1,63 @@
+// Package cli implements the analytics-util command. It lives outside of package main so that
+// worker repos can build their own copy of the command.
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Exit codes shared by every command.
+const (
+	exitOK    = 0
+	exitFail  = 1
+	exitUsage = 2
+)
+
+type command struct {
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"freshness": {summary: "check whether tables are fresh enough to skip their jobs", run: freshnessCommand},
+	"payload":   {summary: "inspect, lint and advance workflow payloads", run: payloadCommand},
+	"run":       {summary: "run a workflow locally by chaining worker binaries", run: runCommand},
+	"schema":    {summary: "print the JSON Schema of a registered worker's config", run: schemaCommand},
+}
+
+// Run runs the analytics-util subcommand named by args[0] and returns the process exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+	return cmd.run(args[1:], stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: analytics-util <command> [arguments]")
+	printCommands(w, commands)
+}
+
+func printCommands(w io.Writer, cmds map[string]command) {
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "\ncommands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s%s%s\n", name, strings.Repeat(" ", 12-len(name)), cmds[name].summary)
+	}
+}
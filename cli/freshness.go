@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	kvlogger "gopkg.in/Clever/kayvee-go.v6/logger"
+
+	"github.com/Clever/analytics-util/analyticspipeline"
+)
+
+// exitCheckError is the exit code of a freshness check that could not reach ALCS or failed, so
+// that scripts can tell it apart from stale data (exitFail) and bad usage (exitUsage).
+const exitCheckError = 3
+
+// newALCSClient returns the ALCS client to check freshness with: the one at url, or the one found
+// through discovery. Tests replace it.
+var newALCSClient = func(url string) (alcsWagClient.Client, error) {
+	if url != "" {
+		return alcsWagClient.New(url), nil
+	}
+	return alcsWagClient.NewFromDiscovery()
+}
+
+// freshnessOutput is a table's freshness as printed with -format json.
+type freshnessOutput struct {
+	Database  alcs.AnalyticsDatabase `json:"database"`
+	Schema    string                 `json:"schema"`
+	Table     string                 `json:"table"`
+	Reason    string                 `json:"reason"`
+	Fresh     bool                   `json:"fresh"`
+	Latency   *float64               `json:"latency"`
+	Threshold string                 `json:"threshold,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// freshnessCommand answers "would this job run right now?" for one or more tables. It exits 0 if
+// every table is fresh, 1 if any is stale and exitCheckError if any check failed.
+func freshnessCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("freshness", flag.ContinueOnError)
+	var (
+		tablesFile  = fs.String("tables-file", "", "check the tables in this file, one \"database schema table\" per line")
+		format      = fs.String("format", "text", "output format: text or json")
+		timeout     = fs.Duration("timeout", 30*time.Second, "give up on a table after this long")
+		alcsURL     = fs.String("alcs-url", "", "ALCS base URL; defaults to finding ALCS through discovery")
+		latencyFile = fs.String("latency-file", "", "read last-load timestamps from this file instead of asking ALCS")
+		verbose     = fs.Bool("v", false, "write the kayvee log events of each check to stderr")
+	)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: analytics-util freshness [flags] <database> <schema> <table>")
+		fmt.Fprintln(stderr, "       analytics-util freshness [flags] -tables-file <file>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		return exitUsage
+	}
+
+	var tables []analyticspipeline.Table
+	switch {
+	case *tablesFile != "" && fs.NArg() == 0:
+		var err error
+		if tables, err = readTablesFile(*tablesFile); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+	case *tablesFile == "" && fs.NArg() == 3:
+		tables = []analyticspipeline.Table{{Database: alcs.AnalyticsDatabase(fs.Arg(0)), Schema: fs.Arg(1), Name: fs.Arg(2)}}
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+
+	logger := kvlogger.New("analytics-util")
+	logger.SetOutput(ioutil.Discard)
+	if *verbose {
+		logger.SetOutput(stderr)
+	}
+	checker := &analyticspipeline.FreshnessChecker{Logger: logger, Timeout: *timeout}
+	if *latencyFile != "" {
+		checker.Source = &analyticspipeline.FileSource{Path: *latencyFile}
+	} else {
+		client, err := newALCSClient(*alcsURL)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitCheckError
+		}
+		checker.Client = client
+	}
+
+	results := checker.CheckTables(context.Background(), tables, analyticspipeline.DefaultBatchConcurrency)
+	if *format == "json" {
+		printFreshnessJSON(stdout, tables, results)
+	} else {
+		printFreshnessText(stdout, tables, results)
+	}
+	return freshnessExitCode(results)
+}
+
+// readTablesFile reads a list of tables, one "database schema table" per line. Blank lines and
+// lines starting with # are ignored.
+func readTablesFile(path string) ([]analyticspipeline.Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tables []analyticspipeline.Table
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected \"database schema table\", got %q", path, line, text)
+		}
+		tables = append(tables, analyticspipeline.Table{Database: alcs.AnalyticsDatabase(fields[0]), Schema: fields[1], Name: fields[2]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("%s: no tables", path)
+	}
+	return tables, nil
+}
+
+// freshnessError reports whether a result means the check failed, rather than found stale data.
+func freshnessError(result analyticspipeline.FreshnessResult) bool {
+	switch result.Reason {
+	case analyticspipeline.ReasonError, analyticspipeline.ReasonTimeout, analyticspipeline.ReasonInvalidThreshold:
+		return true
+	default:
+		return false
+	}
+}
+
+func freshnessExitCode(results map[analyticspipeline.Table]analyticspipeline.FreshnessResult) int {
+	code := exitOK
+	for _, result := range results {
+		if freshnessError(result) {
+			return exitCheckError
+		} else if !result.Fresh {
+			code = exitFail
+		}
+	}
+	return code
+}
+
+// uniqueTables returns tables without duplicates, in order.
+func uniqueTables(tables []analyticspipeline.Table) []analyticspipeline.Table {
+	seen := map[analyticspipeline.Table]bool{}
+	unique := []analyticspipeline.Table{}
+	for _, table := range tables {
+		if !seen[table] {
+			seen[table] = true
+			unique = append(unique, table)
+		}
+	}
+	return unique
+}
+
+func printFreshnessText(w io.Writer, tables []analyticspipeline.Table, results map[analyticspipeline.Table]analyticspipeline.FreshnessResult) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tREASON\tLATENCY\tTHRESHOLD\tERROR")
+	for _, table := range uniqueTables(tables) {
+		result := results[table]
+		latency := "-"
+		if result.Latency != nil {
+			latency = fmt.Sprintf("%.2fh", *result.Latency)
+		}
+		threshold := result.Threshold
+		if threshold == "" {
+			threshold = "-"
+		}
+		errText := "-"
+		if result.Err != nil {
+			errText = result.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", table, result.Reason, latency, threshold, errText)
+	}
+	tw.Flush()
+}
+
+// printFreshnessJSON prints one JSON object per table, so that the output can be piped to jq.
+func printFreshnessJSON(w io.Writer, tables []analyticspipeline.Table, results map[analyticspipeline.Table]analyticspipeline.FreshnessResult) {
+	encoder := json.NewEncoder(w)
+	for _, table := range uniqueTables(tables) {
+		result := results[table]
+		output := freshnessOutput{
+			Database:  table.Database,
+			Schema:    table.Schema,
+			Table:     table.Name,
+			Reason:    string(result.Reason),
+			Fresh:     result.Fresh,
+			Latency:   result.Latency,
+			Threshold: result.Threshold,
+		}
+		if result.Err != nil {
+			output.Error = result.Err.Error()
+		}
+		encoder.Encode(output)
+	}
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	alcsWagClient "github.com/Clever/analytics-latency-config-service/gen-go/client"
+	alcs "github.com/Clever/analytics-latency-config-service/gen-go/models"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Clever/analytics-util/alcstest"
+)
+
+// withALCS makes the freshness command talk to client.
+func withALCS(client alcsWagClient.Client) func() {
+	original := newALCSClient
+	newALCSClient = func(url string) (alcsWagClient.Client, error) { return client, nil }
+	return func() { newALCSClient = original }
+}
+
+func fakeALCS() *alcstest.Client {
+	client := alcstest.New()
+	for table, latency := range map[string]float64{"schools": 5, "sections": 15} {
+		client.SetLatency(alcs.AnalyticsDatabaseRedshiftFast, "public", table, latency)
+		client.SetThresholds(alcs.AnalyticsDatabaseRedshiftFast, "public", table, alcs.Thresholds{Refresh: "10h"})
+	}
+	client.SetError(alcs.AnalyticsDatabaseRedshiftFast, "public", "students", errors.New("connection error"))
+	return client
+}
+
+func TestFreshness(t *testing.T) {
+	defer withALCS(fakeALCS())()
+	db := string(alcs.AnalyticsDatabaseRedshiftFast)
+
+	code, stdout, _ := runCLI("freshness", db, "public", "schools")
+	require.Equal(t, exitOK, code)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, []string{"TABLE", "REASON", "LATENCY", "THRESHOLD", "ERROR"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{db + ":public.schools", "fresh", "5.00h", "10h", "-"}, strings.Fields(lines[1]))
+
+	code, _, _ = runCLI("freshness", db, "public", "sections")
+	require.Equal(t, exitFail, code)
+
+	code, stdout, _ = runCLI("freshness", "-format", "json", db, "public", "students")
+	require.Equal(t, exitCheckError, code)
+	var output freshnessOutput
+	require.NoError(t, json.Unmarshal([]byte(stdout), &output))
+	require.Equal(t, freshnessOutput{Database: alcs.AnalyticsDatabaseRedshiftFast, Schema: "public", Table: "students", Reason: "error", Error: "connection error"}, output)
+}
+
+func TestFreshnessTablesFile(t *testing.T) {
+	defer withALCS(fakeALCS())()
+	db := string(alcs.AnalyticsDatabaseRedshiftFast)
+
+	f, err := ioutil.TempFile("", "tables")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("# tables to check\n" + db + " public schools\n\n" + db + " public sections\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	code, stdout, _ := runCLI("freshness", "-tables-file", f.Name(), "-format", "json")
+	require.Equal(t, exitFail, code, "one stale table makes the check fail")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"table":"schools","reason":"fresh"`)
+	require.Contains(t, lines[1], `"table":"sections","reason":"stale"`)
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(db+" schools\n"), 0644))
+	code, _, stderr := runCLI("freshness", "-tables-file", f.Name())
+	require.Equal(t, exitUsage, code)
+	require.Contains(t, stderr, ":1: expected \"database schema table\"")
+}
+
+func TestFreshnessLatencyFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "latencies")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"tables": [{"schema": "public", "table": "schools", "last_loaded": "2000-01-01T00:00:00Z", "thresholds": {"refresh": "10h"}}]}`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	code, stdout, _ := runCLI("freshness", "-latency-file", f.Name(), "RedshiftFast", "public", "schools")
+	require.Equal(t, exitFail, code)
+	require.Contains(t, stdout, "stale")
+}
+
+func TestFreshnessUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"freshness"},
+		{"freshness", "RedshiftFast", "public"},
+		{"freshness", "-format", "yaml", "RedshiftFast", "public", "schools"},
+		{"freshness", "-tables-file", "tables.txt", "RedshiftFast", "public", "schools"},
+	} {
+		code, _, _ := runCLI(args...)
+		require.Equal(t, exitUsage, code, "%v", args)
+	}
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Clever/analytics-util/analyticspipeline"
+)
+
+var payloadSubcommands = map[string]command{
+	"inspect":  {summary: "pretty-print the steps of a payload", run: payloadInspectCommand},
+	"lint":     {summary: "check a payload for malformed steps", run: payloadLintCommand},
+	"next":     {summary: "print the payload AnalyticsWorker would emit for the next step", run: payloadNextCommand},
+	"validate": {summary: "check every step of a payload against its registered worker config", run: payloadValidateCommand},
+}
+
+func payloadCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		payloadUsage(stderr)
+		return exitUsage
+	}
+	cmd, ok := payloadSubcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown payload command %q\n", args[0])
+		payloadUsage(stderr)
+		return exitUsage
+	}
+	return cmd.run(args[1:], stdout, stderr)
+}
+
+func payloadUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: analytics-util payload <command> [-payload-file file | <payload>]")
+	printCommands(w, payloadSubcommands)
+}
+
+// parsePayloadFlags parses the flags shared by the payload commands and returns the raw payload.
+func parsePayloadFlags(name string, args []string, stderr io.Writer) ([]byte, bool) {
+	fs := flag.NewFlagSet("payload "+name, flag.ContinueOnError)
+	payloadFile := fs.String("payload-file", "", "read the payload from this file instead of the first argument")
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return nil, false
+	}
+	raw, err := readPayloadArg(fs, *payloadFile)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, false
+	}
+	return raw, true
+}
+
+func payloadInspectCommand(args []string, stdout, stderr io.Writer) int {
+	raw, ok := parsePayloadFlags("inspect", args, stderr)
+	if !ok {
+		return exitUsage
+	}
+	payload, err := analyticspipeline.ParsePayload(raw)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFail
+	}
+
+	attempt := payload.Attempt
+	if attempt < 1 {
+		attempt = 1
+	}
+	fmt.Fprintf(stdout, "done: %t\nattempt: %d\nsteps: %d\n", payload.Done, attempt, len(payload.Remanining)+1)
+	printStep(stdout, "current", payload.Current)
+	for i, step := range payload.Remanining {
+		printStep(stdout, fmt.Sprintf("remaining[%d]", i), step)
+	}
+	return exitOK
+}
+
+func printStep(w io.Writer, label string, step map[string]interface{}) {
+	if name := analyticspipeline.StepName(step); name != "" {
+		label += " " + name
+	}
+	b, err := json.MarshalIndent(step, "  ", "  ")
+	if err != nil {
+		b = []byte(err.Error())
+	}
+	fmt.Fprintf(w, "\n%s\n  %s\n", label, b)
+}
+
+func payloadLintCommand(args []string, stdout, stderr io.Writer) int {
+	raw, ok := parsePayloadFlags("lint", args, stderr)
+	if !ok {
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, issue := range analyticspipeline.LintPayload(raw) {
+		fmt.Fprintln(stdout, issue)
+		if issue.Severity == analyticspipeline.LintError {
+			code = exitFail
+		}
+	}
+	return code
+}
+
+func payloadNextCommand(args []string, stdout, stderr io.Writer) int {
+	raw, ok := parsePayloadFlags("next", args, stderr)
+	if !ok {
+		return exitUsage
+	}
+	payload, err := analyticspipeline.ParsePayload(raw)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFail
+	}
+
+	b, err := json.Marshal(payload.Next())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFail
+	}
+	fmt.Fprintln(stdout, string(b))
+	return exitOK
+}
+
+// payloadValidateCommand checks a payload against the workers registered in this binary. The
+// stock analytics-util registers none, so worker repos embed cli.Run in their own binary to use it.
+func payloadValidateCommand(args []string, stdout, stderr io.Writer) int {
+	raw, ok := parsePayloadFlags("validate", args, stderr)
+	if !ok {
+		return exitUsage
+	}
+	payload, err := analyticspipeline.ParsePayload(raw)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFail
+	}
+	if len(analyticspipeline.RegisteredWorkers()) == 0 {
+		fmt.Fprintln(stderr, "no workers are registered in this binary; see analyticspipeline.RegisterWorker")
+		return exitFail
+	}
+
+	code := exitOK
+	for _, issue := range analyticspipeline.ValidatePayloadIssues(payload) {
+		fmt.Fprintln(stdout, issue)
+		if issue.Severity == analyticspipeline.LintError {
+			code = exitFail
+		}
+	}
+	return code
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Clever/analytics-util/analyticspipeline"
+)
+
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestPayloadInspect(t *testing.T) {
+	code, stdout, _ := runCLI("payload", "inspect",
+		`{"current":{"step":"extract"},"remaining":[{"step":"load","table":"schools"}],"attempt":2}`)
+	require.Equal(t, exitOK, code)
+	require.Equal(t, `done: false
+attempt: 2
+steps: 2
+
+current extract
+  {
+    "step": "extract"
+  }
+
+remaining[0] load
+  {
+    "step": "load",
+    "table": "schools"
+  }
+`, stdout)
+}
+
+func TestPayloadLint(t *testing.T) {
+	code, stdout, _ := runCLI("payload", "lint", `{"current":{},"remaining":[{}]}`)
+	require.Equal(t, exitOK, code)
+	require.Empty(t, stdout)
+
+	code, stdout, _ = runCLI("payload", "lint", `{"district_id":"abc123"}`)
+	require.Equal(t, exitOK, code, "warnings don't fail lint")
+	require.Contains(t, stdout, "warning: $: payload uses the legacy unwrapped format")
+
+	code, stdout, _ = runCLI("payload", "lint", `{"current":[],"remaining":["load"]}`)
+	require.Equal(t, exitFail, code)
+	require.Equal(t, "error: current: must be an object, got list\nerror: remaining[0]: must be an object, got string\n", stdout)
+}
+
+func TestPayloadNext(t *testing.T) {
+	code, stdout, _ := runCLI("payload", "next",
+		`{"current":{"step":"extract"},"remaining":[{"step":"load"},{"step":"report"}],"attempt":3}`)
+	require.Equal(t, exitOK, code)
+	require.Equal(t, `{"current":{"step":"load"},"remaining":[{"step":"report"}],"done":false}`+"\n", stdout)
+
+	code, stdout, _ = runCLI("payload", "next", `{"district_id":"abc123"}`)
+	require.Equal(t, exitOK, code)
+	require.Equal(t, `{"current":{},"remaining":[],"done":true}`+"\n", stdout)
+}
+
+func TestPayloadValidate(t *testing.T) {
+	code, _, stderr := runCLI("payload", "validate", `{"current":{"step":"load"}}`)
+	require.Equal(t, exitFail, code)
+	require.Contains(t, stderr, "no workers are registered")
+
+	defer analyticspipeline.UnregisterWorker("load")
+	require.NoError(t, analyticspipeline.RegisterWorker("load", struct {
+		Table string `config:"table,required"`
+	}{}))
+
+	code, stdout, _ := runCLI("payload", "validate", `{"current":{"step":"load","table":"schools"},"remaining":[{"step":"load"}]}`)
+	require.Equal(t, exitFail, code)
+	require.Equal(t, "error: remaining[0]: missing required field table for step load\n", stdout)
+
+	code, _, _ = runCLI("payload", "validate", `{"current":{"step":"load","table":"schools"},"remaining":[{"step":"load","table":"students"}]}`)
+	require.Equal(t, exitOK, code)
+
+	code, stdout, _ = runCLI("payload", "validate", `{"current":{"step":"load","table":"schools","tabel":"students"}}`)
+	require.Equal(t, exitOK, code, "unknown keys are ignored by AnalyticsWorker")
+	require.Equal(t, "warning: current.tabel: unknown field for step load\n", stdout)
+}
+
+func TestPayloadUsage(t *testing.T) {
+	code, _, stderr := runCLI("payload", "explode", "{}")
+	require.Equal(t, exitUsage, code)
+	require.Contains(t, stderr, `unknown payload command "explode"`)
+
+	code, _, _ = runCLI("payload", "next")
+	require.Equal(t, exitUsage, code)
+}
@@ -0,0 +1,323 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Clever/analytics-util/analyticspipeline"
+)
+
+// stepBinaries maps step names to worker binaries. It implements flag.Value so that it can be
+// built from repeated -step name=binary flags.
+type stepBinaries map[string]string
+
+func (s stepBinaries) String() string {
+	pairs := make([]string, 0, len(s))
+	for name, binary := range s {
+		pairs = append(pairs, name+"="+binary)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (s stepBinaries) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected name=binary, got %q", value)
+	}
+	s[parts[0]] = parts[1]
+	return nil
+}
+
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	var (
+		binaries    = stepBinaries{}
+		fs          = flag.NewFlagSet("run", flag.ContinueOnError)
+		payloadFile = fs.String("payload-file", "", "read the workflow payload from this file instead of the first argument")
+		parallelism = fs.Int("parallelism", 4, "maximum number of fan-out invocations of a step to run at once")
+	)
+	fs.Var(binaries, "step", "a step name and the worker binary that runs it, as name=binary. May be repeated")
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: analytics-util run -step name=binary [-step name=binary ...] <payload>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	raw, err := readPayloadArg(fs, *payloadFile)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	var payload analyticspipeline.Payload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		fmt.Fprintf(stderr, "invalid workflow payload: %s\n", err)
+		return exitUsage
+	}
+	if *parallelism < 1 {
+		fmt.Fprintln(stderr, "-parallelism must be at least 1")
+		return exitUsage
+	}
+
+	r := &runner{
+		binaries:    binaries,
+		parallelism: *parallelism,
+		stderr:      &lockedWriter{w: stderr},
+		sleep:       time.Sleep,
+	}
+	start := time.Now()
+	results, err := r.run(context.Background(), payload)
+	printRunSummary(stdout, results, time.Since(start), err)
+	if err != nil {
+		return exitFail
+	}
+	return exitOK
+}
+
+// readPayloadArg returns the payload from payloadFile if it is set, or from the first argument.
+func readPayloadArg(fs *flag.FlagSet, payloadFile string) ([]byte, error) {
+	if payloadFile != "" {
+		return ioutil.ReadFile(payloadFile)
+	}
+	if fs.NArg() != 1 {
+		return nil, errors.New("expected a single workflow payload argument")
+	}
+	return []byte(fs.Arg(0)), nil
+}
+
+// stepResult is the outcome of one step of a workflow run.
+type stepResult struct {
+	index       int
+	name        string
+	invocations int
+	attempts    int
+	duration    time.Duration
+	err         error
+}
+
+// runner runs a workflow by executing each step's worker binary with the payload emitted by the
+// step before it.
+type runner struct {
+	binaries    stepBinaries
+	parallelism int
+	stderr      io.Writer
+	sleep       func(time.Duration)
+}
+
+func (r *runner) run(ctx context.Context, payload analyticspipeline.Payload) ([]stepResult, error) {
+	if err := r.checkBinaries(payload); err != nil {
+		return nil, err
+	}
+
+	results := []stepResult{}
+	for index := 0; !payload.Done; index++ {
+		start := time.Now()
+		result, next := r.runStep(ctx, index, payload)
+		result.duration = time.Since(start)
+		results = append(results, result)
+		if result.err != nil {
+			return results, fmt.Errorf("step %d (%s): %s", index, result.name, result.err)
+		}
+		payload = *next
+	}
+	return results, nil
+}
+
+// checkBinaries makes sure every step has a binary before anything runs, so that a typo in the
+// last step doesn't surface after the earlier ones have finished.
+func (r *runner) checkBinaries(payload analyticspipeline.Payload) error {
+	if payload.Done {
+		return nil
+	}
+	steps := append([]map[string]interface{}{payload.Current}, payload.Remanining...)
+	for i, step := range steps {
+		name := analyticspipeline.StepName(step)
+		if name == "" {
+			return fmt.Errorf("step %d has no %q", i, analyticspipeline.StepKey)
+		}
+		if _, ok := r.binaries[name]; !ok {
+			return fmt.Errorf("step %d (%s) has no binary", i, name)
+		}
+	}
+	return nil
+}
+
+func (r *runner) runStep(ctx context.Context, index int, payload analyticspipeline.Payload) (stepResult, *analyticspipeline.Payload) {
+	result := stepResult{index: index, name: analyticspipeline.StepName(payload.Current)}
+
+	retry, err := analyticspipeline.RetryPolicyForStep(payload.Current)
+	if err != nil {
+		result.err = err
+		return result, nil
+	}
+	invocations, err := analyticspipeline.ExpandFanOut(payload.Current)
+	if err != nil {
+		result.err = err
+		return result, nil
+	}
+	result.invocations = len(invocations)
+
+	var (
+		binary   = r.binaries[result.name]
+		emitted  = make([]*analyticspipeline.Payload, len(invocations))
+		attempts = make([]int, len(invocations))
+		errs     = make([]error, len(invocations))
+		sem      = make(chan struct{}, r.parallelism)
+		wg       sync.WaitGroup
+	)
+	for i, current := range invocations {
+		wg.Add(1)
+		go func(i int, current map[string]interface{}) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			invocation := analyticspipeline.Payload{Current: current, Remanining: payload.Remanining}
+			emitted[i], attempts[i], errs[i] = r.invoke(ctx, binary, invocation, retry)
+		}(i, current)
+	}
+	wg.Wait()
+
+	for i := range invocations {
+		result.attempts += attempts[i]
+		if errs[i] != nil && result.err == nil {
+			result.err = errs[i]
+			if len(invocations) > 1 {
+				result.err = fmt.Errorf("fan-out invocation %d: %s", i, errs[i])
+			}
+		}
+	}
+	if result.err != nil {
+		return result, nil
+	}
+	// every invocation of a step shares its remaining steps, so any of them can drive the next step
+	return result, emitted[0]
+}
+
+// invoke runs a single invocation of a step, retrying it as its policy allows. It returns the
+// emitted payload and the number of attempts made.
+func (r *runner) invoke(ctx context.Context, binary string, payload analyticspipeline.Payload, retry *analyticspipeline.RetryPolicy) (*analyticspipeline.Payload, int, error) {
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			payload.Attempt = attempt
+		}
+		next, exitCode, err := r.exec(ctx, binary, payload)
+		if err == nil {
+			return next, attempt, nil
+		}
+		if !retry.ShouldRetry(attempt, exitCode) {
+			return nil, attempt, err
+		}
+		delay := retry.Delay(attempt)
+		fmt.Fprintf(r.stderr, "%s failed on attempt %d (%s), retrying in %s\n", binary, attempt, err, delay)
+		r.sleep(delay)
+	}
+}
+
+// exec runs binary with the payload as its only argument and returns the payload it emitted along
+// with its exit code. Everything else the worker writes to stdout is passed through to stderr.
+func (r *runner) exec(ctx context.Context, binary string, payload analyticspipeline.Payload) (*analyticspipeline.Payload, int, error) {
+	arg, err := json.Marshal(payload)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, string(arg))
+	cmd.Stdout = &stdout
+	cmd.Stderr = r.stderr
+	if err := cmd.Run(); err != nil {
+		r.stderr.Write(stdout.Bytes())
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, exitErr.ExitCode(), err
+		}
+		return nil, -1, err
+	}
+
+	next, rest := splitEmittedPayload(stdout.Bytes())
+	r.stderr.Write(rest)
+	if next == nil {
+		return nil, 0, errors.New("worker exited without printing a payload")
+	}
+	return next, 0, nil
+}
+
+// splitEmittedPayload finds the last line of worker output that is a payload, as printed by
+// analyticspipeline.PrintPayload, and returns it along with the rest of the output.
+func splitEmittedPayload(output []byte) (*analyticspipeline.Payload, []byte) {
+	var (
+		lines   []string
+		payload *analyticspipeline.Payload
+		found   = -1
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, len(output)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lines = append(lines, line)
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			continue
+		}
+		if _, ok := fields["done"]; !ok {
+			continue
+		}
+		var p analyticspipeline.Payload
+		if err := json.Unmarshal([]byte(line), &p); err == nil {
+			payload, found = &p, len(lines)-1
+		}
+	}
+
+	var rest bytes.Buffer
+	for i, line := range lines {
+		if i != found {
+			rest.WriteString(line + "\n")
+		}
+	}
+	return payload, rest.Bytes()
+}
+
+func printRunSummary(w io.Writer, results []stepResult, elapsed time.Duration, runErr error) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tNAME\tINVOCATIONS\tATTEMPTS\tDURATION\tSTATUS")
+	for _, result := range results {
+		status := "ok"
+		if result.err != nil {
+			status = "failed"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%s\t%s\n", result.index, result.name, result.invocations,
+			result.attempts, result.duration.Round(time.Millisecond), status)
+	}
+	tw.Flush()
+
+	if runErr != nil {
+		fmt.Fprintf(w, "workflow failed after %s: %s\n", elapsed.Round(time.Millisecond), runErr)
+		return
+	}
+	fmt.Fprintf(w, "workflow finished %d steps in %s\n", len(results), elapsed.Round(time.Millisecond))
+}
+
+// lockedWriter serializes writes from concurrently running workers.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Clever/analytics-util/analyticspipeline"
+)
+
+// testWorkerEnv makes the test binary act as an analytics worker, so that the runner has a real
+// binary to execute.
+const testWorkerEnv = "ANALYTICS_UTIL_TEST_WORKER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testWorkerEnv) != "" {
+		os.Exit(testWorker())
+	}
+	os.Setenv(testWorkerEnv, "1")
+	os.Exit(m.Run())
+}
+
+// testWorker emits the next payload, unless it is configured to fail before a given attempt.
+func testWorker() int {
+	var config struct {
+		Table            string `config:"table"`
+		FailWith         string `config:"fail_with"`
+		SucceedOnAttempt string `config:"succeed_on_attempt"`
+	}
+	var info analyticspipeline.StepInfo
+	payload, err := analyticspipeline.AnalyticsWorker(&config, analyticspipeline.WithStepInfo(&info))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if config.FailWith != "" {
+		succeedOn, _ := strconv.Atoi(config.SucceedOnAttempt)
+		if succeedOn == 0 || info.Attempt < succeedOn {
+			code, _ := strconv.Atoi(config.FailWith)
+			return code
+		}
+	}
+	fmt.Printf("loaded table %q\n", config.Table)
+	analyticspipeline.PrintPayload(payload)
+	return 0
+}
+
+func testRunner(steps ...string) (*runner, *bytes.Buffer) {
+	binaries := stepBinaries{}
+	for _, step := range steps {
+		binaries[step] = os.Args[0]
+	}
+	var stderr bytes.Buffer
+	return &runner{
+		binaries:    binaries,
+		parallelism: 2,
+		stderr:      &lockedWriter{w: &stderr},
+		sleep:       func(time.Duration) {},
+	}, &stderr
+}
+
+func step(name string, config map[string]interface{}) map[string]interface{} {
+	s := map[string]interface{}{analyticspipeline.StepKey: name}
+	for k, v := range config {
+		s[k] = v
+	}
+	return s
+}
+
+func TestRunnerChainsSteps(t *testing.T) {
+	r, stderr := testRunner("extract", "load")
+	results, err := r.run(context.Background(), analyticspipeline.Payload{
+		Current: step("extract", map[string]interface{}{"table": "schools"}),
+		Remanining: []map[string]interface{}{
+			step("load", map[string]interface{}{"table": "schools"}),
+			step("load", map[string]interface{}{"table": "students"}),
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for i, result := range results {
+		require.Equal(t, i, result.index)
+		require.Equal(t, 1, result.attempts)
+		require.NoError(t, result.err)
+	}
+	require.Equal(t, "extract", results[0].name)
+	require.Equal(t, "load", results[2].name)
+	require.Contains(t, stderr.String(), `loaded table "students"`)
+}
+
+func TestRunnerRetries(t *testing.T) {
+	r, _ := testRunner("load")
+	results, err := r.run(context.Background(), analyticspipeline.Payload{
+		Current: step("load", map[string]interface{}{
+			"fail_with":          "75",
+			"succeed_on_attempt": "3",
+			analyticspipeline.RetryKey: map[string]interface{}{
+				"max_attempts":         5,
+				"retryable_exit_codes": []int{75},
+			},
+		}),
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, 3, results[0].attempts)
+}
+
+func TestRunnerStopsOnFailure(t *testing.T) {
+	r, _ := testRunner("extract", "load")
+	results, err := r.run(context.Background(), analyticspipeline.Payload{
+		Current: step("extract", map[string]interface{}{
+			"fail_with":                "1",
+			analyticspipeline.RetryKey: map[string]interface{}{"max_attempts": 3, "retryable_exit_codes": []int{75}},
+		}),
+		Remanining: []map[string]interface{}{step("load", nil)},
+	})
+	require.Error(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, 1, results[0].attempts, "exit code 1 is not retryable")
+}
+
+func TestRunnerFansOut(t *testing.T) {
+	r, stderr := testRunner("load")
+	results, err := r.run(context.Background(), analyticspipeline.Payload{
+		Current: step("load", map[string]interface{}{
+			analyticspipeline.FanOutKey: []interface{}{
+				map[string]interface{}{"table": "schools"},
+				map[string]interface{}{"table": "students"},
+				map[string]interface{}{"table": "teachers"},
+			},
+		}),
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, 3, results[0].invocations)
+	require.Equal(t, 3, results[0].attempts)
+	for _, table := range []string{"schools", "students", "teachers"} {
+		require.Contains(t, stderr.String(), fmt.Sprintf("loaded table %q", table))
+	}
+}
+
+func TestRunnerChecksBinariesUpFront(t *testing.T) {
+	r, _ := testRunner("extract")
+	results, err := r.run(context.Background(), analyticspipeline.Payload{
+		Current:    step("extract", nil),
+		Remanining: []map[string]interface{}{step("laod", nil)},
+	})
+	require.EqualError(t, err, "step 1 (laod) has no binary")
+	require.Empty(t, results)
+}
+
+func TestSplitEmittedPayload(t *testing.T) {
+	output := []byte("{\"title\":\"starting\",\"level\":\"info\"}\n" +
+		"{\"current\":{\"table\":\"schools\"},\"remaining\":[],\"done\":false}\n" +
+		"bye\n")
+	payload, rest := splitEmittedPayload(output)
+	require.Equal(t, &analyticspipeline.Payload{
+		Current:    map[string]interface{}{"table": "schools"},
+		Remanining: []map[string]interface{}{},
+	}, payload)
+	require.Equal(t, "{\"title\":\"starting\",\"level\":\"info\"}\nbye\n", string(rest))
+
+	payload, _ = splitEmittedPayload([]byte("no payload here\n"))
+	require.Nil(t, payload)
+}
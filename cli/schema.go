@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Clever/analytics-util/analyticspipeline"
+)
+
+// schemaCommand prints the JSON Schema of a worker registered in this binary, or lists the
+// registered workers when no step is given.
+func schemaCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: analytics-util schema [step]")
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	steps := analyticspipeline.RegisteredWorkers()
+	if len(steps) == 0 {
+		fmt.Fprintln(stderr, "no workers are registered in this binary; see analyticspipeline.RegisterWorker")
+		return exitFail
+	}
+
+	switch fs.NArg() {
+	case 0:
+		for _, step := range steps {
+			fmt.Fprintln(stdout, step)
+		}
+		return exitOK
+	case 1:
+		schema, err := analyticspipeline.WorkerSchema(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFail
+		}
+		b, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFail
+		}
+		fmt.Fprintln(stdout, string(b))
+		return exitOK
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+}
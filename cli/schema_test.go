@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Clever/analytics-util/analyticspipeline"
+)
+
+func TestSchema(t *testing.T) {
+	defer analyticspipeline.UnregisterWorker("report")
+	require.NoError(t, analyticspipeline.RegisterWorker("report", struct {
+		Table string `config:"table,required" description:"the table to report on"`
+	}{}))
+
+	code, stdout, _ := runCLI("schema")
+	require.Equal(t, exitOK, code)
+	require.Contains(t, stdout, "report\n")
+
+	code, stdout, _ = runCLI("schema", "report")
+	require.Equal(t, exitOK, code)
+	var schema analyticspipeline.JSONSchema
+	require.NoError(t, json.Unmarshal([]byte(stdout), &schema))
+	require.Equal(t, "report", schema.Title)
+	require.Equal(t, "the table to report on", schema.Properties["table"].Description)
+
+	code, _, stderr := runCLI("schema", "missing")
+	require.Equal(t, exitFail, code)
+	require.Contains(t, stderr, "no worker is registered for step missing")
+}
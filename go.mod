@@ -24,5 +24,5 @@ require (
 	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
 	golang.org/x/tools v0.1.5 // indirect
 	gopkg.in/Clever/kayvee-go.v6 v6.24.0
-	gopkg.in/yaml.v2 v2.3.1-0.20200602174213-b893565b90ca // indirect
+	gopkg.in/yaml.v2 v2.3.1-0.20200602174213-b893565b90ca
 )
@@ -0,0 +1,59 @@
+package metadata
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// PercentEncodingV1 is the x-amz-meta-field-encoding of field names written with encodeFieldName:
+// UTF-8, with commas, percent signs and every byte outside of printable ASCII written as %XX
+const PercentEncodingV1 = "percent-v1"
+
+// needsEncoding reports whether a field name can't be written to field-names as-is
+func needsEncoding(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if escapeByte(name[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func escapeByte(b byte) bool {
+	return b == ',' || b == '%' || b < ' ' || b > '~'
+}
+
+// encodeFieldName percent-encodes a field name, see PercentEncodingV1
+func encodeFieldName(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if b := name[i]; escapeByte(b) {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		} else {
+			sb.WriteByte(b)
+		}
+	}
+	return sb.String()
+}
+
+// decodeFieldName reverses encodeFieldName
+func decodeFieldName(name string) (string, error) {
+	decoded, err := url.PathUnescape(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid field name encoding %q: %s", name, err)
+	}
+	return decoded, nil
+}
+
+// validateFieldName rejects field names that can't be encoded
+func validateFieldName(name string) error {
+	if name == "" {
+		return fmt.Errorf(invalidFieldErrorTemplate, "field name")
+	}
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("field name %q is not valid UTF-8", name)
+	}
+	return nil
+}
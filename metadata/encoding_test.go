@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const mFieldEncoding = "x-amz-meta-field-encoding"
+
+func TestEncodeFieldName(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "id", encoded: "id"},
+		{name: "a,b", encoded: "a%2Cb"},
+		{name: "100%", encoded: "100%25"},
+		{name: "café", encoded: "caf%C3%A9"},
+		{name: "tab\there", encoded: "tab%09here"},
+		{name: "with space+plus", encoded: "with space+plus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.encoded, encodeFieldName(tt.name))
+			decoded, err := decodeFieldName(tt.encoded)
+			require.NoError(t, err)
+			require.Equal(t, tt.name, decoded)
+		})
+	}
+
+	_, err := decodeFieldName("bad%zz")
+	require.Error(t, err)
+}
+
+func TestFieldEncoding(t *testing.T) {
+	fields := []Field{
+		{Name: "id", Type: UUID},
+		{Name: "city,state", Type: String},
+		{Name: "prénom", Type: String},
+	}
+	m, err := GenerateOrderedS3MetaData(testSchema, testTable, fields)
+	require.NoError(t, err)
+	require.Equal(t, "id,city%2Cstate,pr%C3%A9nom", *m[mFieldNames])
+	require.Equal(t, PercentEncodingV1, *m[mFieldEncoding])
+
+	parsed, err := NewS3MetaDataFromSDKMap(m)
+	require.NoError(t, err)
+	require.Equal(t, fields, parsed.OrderedFields)
+
+	// percent signs are escaped so that they can be told apart from escapes
+	m, err = GenerateOrderedS3MetaData(testSchema, testTable, []Field{{Name: "50%_off", Type: Boolean}})
+	require.NoError(t, err)
+	require.Equal(t, "50%25_off", *m[mFieldNames])
+	require.Equal(t, PercentEncodingV1, *m[mFieldEncoding])
+
+	// names that don't need it are written as-is, without the header
+	m, err = GenerateOrderedS3MetaData(testSchema, testTable, []Field{{Name: "id", Type: UUID}})
+	require.NoError(t, err)
+	require.NotContains(t, m, mFieldEncoding)
+
+	// and metadata without the header is read as-is
+	parsed, err = NewS3MetaDataFromSDKMap(map[string]*string{
+		mSchema:     &testSchema,
+		mTable:      &testTable,
+		mFieldNames: testStrPtr("id,50%25"),
+		mFieldTypes: testStrPtr("uuid,integer"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "50%25", parsed.OrderedFields[1].Name)
+}
+
+func TestFieldEncodingInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []Field
+		wantErr string
+	}{
+		{name: "empty name", fields: []Field{{Name: "", Type: String}}, wantErr: "field name is empty"},
+		{name: "invalid UTF-8", fields: []Field{{Name: "bad\xff", Type: String}}, wantErr: "not valid UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateOrderedS3MetaData(testSchema, testTable, tt.fields)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	readTests := []struct {
+		name       string
+		fieldNames string
+		encoding   string
+		wantErr    string
+	}{
+		{name: "unknown encoding", fieldNames: "id", encoding: "base64", wantErr: `unsupported field encoding "base64"`},
+		{name: "malformed escape", fieldNames: "id%2", encoding: PercentEncodingV1, wantErr: "invalid field name encoding"},
+		{name: "decodes to invalid UTF-8", fieldNames: "id%FF", encoding: PercentEncodingV1, wantErr: "not valid UTF-8"},
+	}
+	for _, tt := range readTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewS3MetaDataFromSDKMap(map[string]*string{
+				mSchema:        &testSchema,
+				mTable:         &testTable,
+				mFieldNames:    &tt.fieldNames,
+				mFieldTypes:    testStrPtr("string"),
+				mFieldEncoding: &tt.encoding,
+			})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
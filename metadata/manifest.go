@@ -0,0 +1,141 @@
+package metadata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MaxS3MetaDataSize is S3's limit on the size of user-defined metadata, counted as the bytes of
+// every key and value
+const MaxS3MetaDataSize = 2048
+
+// Header names of the schema and table, which are always written, even when the fields are in a
+// manifest. Readers can match objects to tables with them without parsing the rest of the metadata
+const (
+	SchemaNameHeader = "x-amz-meta-schema-name"
+	TableNameHeader  = "x-amz-meta-table-name"
+)
+
+// Header names of the manifest's key and checksum
+const (
+	fieldManifestHeader       = "x-amz-meta-field-manifest"
+	fieldManifestSHA256Header = "x-amz-meta-field-manifest-sha256"
+)
+
+// ErrMetaDataTooLarge is returned when metadata would be over MaxS3MetaDataSize. Use
+// ConvertToS3SDKFormatWithManifest to move wide tables' fields to a manifest
+var ErrMetaDataTooLarge = errors.New("metadata is over the S3 user-defined metadata limit")
+
+// ManifestWriter stores manifests, e.g. as S3 objects next to the data dump
+type ManifestWriter interface {
+	WriteManifest(key string, manifest []byte) error
+}
+
+// ManifestResolver loads manifests stored by a ManifestWriter
+type ManifestResolver interface {
+	ReadManifest(key string) ([]byte, error)
+}
+
+// ConvertToS3SDKFormatWithManifest converts the S3MetaData to the map expected by the S3 sdk, like
+// ConvertToS3SDKFormat. If the metadata would be over MaxS3MetaDataSize, all of it is written to
+// the manifest at key instead, and the map only has the schema, the table, the key and the
+// manifest's checksum
+func (m *S3MetaData) ConvertToS3SDKFormatWithManifest(writer ManifestWriter, key string) (map[string]*string, error) {
+	s, err := m.convert()
+	if err != nil {
+		return nil, err
+	}
+	if metaDataSize(s) <= MaxS3MetaDataSize {
+		return s, nil
+	}
+
+	manifest, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.WriteManifest(key, manifest); err != nil {
+		return nil, fmt.Errorf("writing manifest %s: %s", key, err)
+	}
+	sum := checksum(manifest)
+	m.FieldManifest = &key
+	m.FieldManifestSHA256 = &sum
+
+	pointer := map[string]*string{
+		SchemaNameHeader:          m.SchemaName,
+		TableNameHeader:           m.TableName,
+		fieldManifestHeader:       m.FieldManifest,
+		fieldManifestSHA256Header: m.FieldManifestSHA256,
+	}
+	if err := checkSize(pointer); err != nil {
+		return nil, err
+	}
+	return pointer, nil
+}
+
+// NewS3MetaDataFromSDKMapWithResolver returns a metadata object constructed from the S3 sdk, like
+// NewS3MetaDataFromSDKMap, loading the fields from their manifest with resolver when they aren't
+// in the metadata itself
+func NewS3MetaDataFromSDKMapWithResolver(metadata map[string]*string, resolver ManifestResolver) (*S3MetaData, error) {
+	return newS3MetaDataFromSDKMap(metadata, resolver)
+}
+
+// resolveManifest fills in the fields from the manifest, if there is one
+func (m *S3MetaData) resolveManifest(resolver ManifestResolver) error {
+	if m.FieldManifest == nil {
+		return nil
+	}
+	key := *m.FieldManifest
+	if resolver == nil {
+		return fmt.Errorf("fields are in manifest %s: reading them needs a ManifestResolver", key)
+	}
+	manifest, err := resolver.ReadManifest(key)
+	if err != nil {
+		return fmt.Errorf("reading manifest %s: %s", key, err)
+	}
+	if m.FieldManifestSHA256 == nil || *m.FieldManifestSHA256 != checksum(manifest) {
+		return fmt.Errorf("manifest %s doesn't match its checksum", key)
+	}
+
+	var full S3MetaData
+	if err := json.Unmarshal(manifest, &full); err != nil {
+		return fmt.Errorf("invalid manifest %s: %s", key, err)
+	}
+	if !equalStrings(full.SchemaName, m.SchemaName) || !equalStrings(full.TableName, m.TableName) {
+		return fmt.Errorf("manifest %s is for a different table", key)
+	}
+	m.FieldNames = full.FieldNames
+	m.FieldTypes = full.FieldTypes
+	m.FieldNullable = full.FieldNullable
+	m.FieldEncoding = full.FieldEncoding
+	return nil
+}
+
+func checkSize(metadata map[string]*string) error {
+	if size := metaDataSize(metadata); size > MaxS3MetaDataSize {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrMetaDataTooLarge, size, MaxS3MetaDataSize)
+	}
+	return nil
+}
+
+func metaDataSize(metadata map[string]*string) int {
+	size := 0
+	for k, v := range metadata {
+		size += len(k)
+		if v != nil {
+			size += len(*v)
+		}
+	}
+	return size
+}
+
+func checksum(manifest []byte) string {
+	sum := sha256.Sum256(manifest)
+	return hex.EncodeToString(sum[:])
+}
+
+func equalStrings(a, b *string) bool {
+	return a != nil && b != nil && *a == *b
+}
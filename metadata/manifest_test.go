@@ -0,0 +1,126 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mFieldManifest       = "x-amz-meta-field-manifest"
+	mFieldManifestSHA256 = "x-amz-meta-field-manifest-sha256"
+)
+
+// memManifests is an in-memory ManifestWriter and ManifestResolver
+type memManifests map[string][]byte
+
+func (s memManifests) WriteManifest(key string, manifest []byte) error {
+	s[key] = manifest
+	return nil
+}
+
+func (s memManifests) ReadManifest(key string) ([]byte, error) {
+	manifest, ok := s[key]
+	if !ok {
+		return nil, fmt.Errorf("no such key")
+	}
+	return manifest, nil
+}
+
+func wideFields(n int) []Field {
+	fields := make([]Field, n)
+	for i := range fields {
+		fields[i] = Field{Name: fmt.Sprintf("column_with_a_long_name_%03d", i), Type: VarcharType(256), Required: i%2 == 0}
+	}
+	return fields
+}
+
+func TestHeaderNames(t *testing.T) {
+	got, err := GenerateOrderedS3MetaData(testSchema, testTable, wideFields(1))
+	require.NoError(t, err)
+	require.Equal(t, testSchema, *got[SchemaNameHeader])
+	require.Equal(t, testTable, *got[TableNameHeader])
+}
+
+func TestMetaDataSizeLimit(t *testing.T) {
+	_, err := GenerateOrderedS3MetaData(testSchema, testTable, wideFields(10))
+	require.NoError(t, err)
+
+	_, err = GenerateOrderedS3MetaData(testSchema, testTable, wideFields(200))
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrMetaDataTooLarge))
+}
+
+func TestManifest(t *testing.T) {
+	store := memManifests{}
+	fields := wideFields(200)
+	m := &S3MetaData{SchemaName: &testSchema, TableName: &testTable, OrderedFields: fields}
+	got, err := m.ConvertToS3SDKFormatWithManifest(store, "dumps/wide.csv.manifest")
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	require.Equal(t, testSchema, *got[mSchema])
+	require.Equal(t, testTable, *got[mTable])
+	require.Equal(t, "dumps/wide.csv.manifest", *got[mFieldManifest])
+	require.Len(t, *got[mFieldManifestSHA256], 64)
+	require.Contains(t, store, "dumps/wide.csv.manifest")
+
+	parsed, err := NewS3MetaDataFromSDKMapWithResolver(got, store)
+	require.NoError(t, err)
+	require.Equal(t, fields, parsed.OrderedFields)
+	require.Len(t, parsed.Fields, 200)
+
+	// narrow tables don't need a manifest
+	m = &S3MetaData{SchemaName: &testSchema, TableName: &testTable, OrderedFields: wideFields(2)}
+	got, err = m.ConvertToS3SDKFormatWithManifest(store, "dumps/narrow.csv.manifest")
+	require.NoError(t, err)
+	require.NotContains(t, got, mFieldManifest)
+	require.NotContains(t, store, "dumps/narrow.csv.manifest")
+	parsed, err = NewS3MetaDataFromSDKMapWithResolver(got, store)
+	require.NoError(t, err)
+	require.Equal(t, wideFields(2), parsed.OrderedFields)
+}
+
+func TestManifestInvalid(t *testing.T) {
+	const key = "dumps/wide.csv.manifest"
+	setup := func(t *testing.T) (memManifests, map[string]*string) {
+		store := memManifests{}
+		m := &S3MetaData{SchemaName: &testSchema, TableName: &testTable, OrderedFields: wideFields(200)}
+		got, err := m.ConvertToS3SDKFormatWithManifest(store, key)
+		require.NoError(t, err)
+		return store, got
+	}
+
+	t.Run("no resolver", func(t *testing.T) {
+		_, got := setup(t)
+		_, err := NewS3MetaDataFromSDKMap(got)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "needs a ManifestResolver")
+	})
+	t.Run("missing manifest", func(t *testing.T) {
+		_, got := setup(t)
+		_, err := NewS3MetaDataFromSDKMapWithResolver(got, memManifests{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "reading manifest "+key)
+	})
+	t.Run("checksum mismatch", func(t *testing.T) {
+		store, got := setup(t)
+		store[key] = append(store[key], ' ')
+		_, err := NewS3MetaDataFromSDKMapWithResolver(got, store)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "doesn't match its checksum")
+	})
+	t.Run("different table", func(t *testing.T) {
+		store, got := setup(t)
+		got[mTable] = testStrPtr("other")
+		_, err := NewS3MetaDataFromSDKMapWithResolver(got, store)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "is for a different table")
+	})
+	t.Run("pointer too large", func(t *testing.T) {
+		m := &S3MetaData{SchemaName: &testSchema, TableName: &testTable, OrderedFields: wideFields(200)}
+		_, err := m.ConvertToS3SDKFormatWithManifest(memManifests{}, string(make([]byte, MaxS3MetaDataSize)))
+		require.True(t, errors.Is(err, ErrMetaDataTooLarge))
+	})
+}
@@ -3,53 +3,104 @@ package metadata
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 )
 
 const (
 	invalidFieldErrorTemplate = "invalid metadata data config: %s is empty"
 	comma                     = ","
+	nullableTrue              = "true"
+	nullableFalse             = "false"
 )
 
 // FieldType represents the currently supported types
 type FieldType string
 
-// Currently supported field types
+// Currently supported field types. Decimal, Varchar and Array also take parameters, see
+// DecimalType, VarcharType and ArrayType.
 const (
 	Boolean   FieldType = "boolean"
 	Integer   FieldType = "integer"
 	MongoID   FieldType = "mongo_id"
 	String    FieldType = "string"
 	Timestamp FieldType = "timestamp"
+	BigInt    FieldType = "bigint"
+	Float     FieldType = "float"
+	Decimal   FieldType = "decimal"
+	Date      FieldType = "date"
+	JSON      FieldType = "json"
+	UUID      FieldType = "uuid"
+	Varchar   FieldType = "varchar"
+	Array     FieldType = "array"
 )
 
+// Field is a named, typed field of a data dump
+type Field struct {
+	Name string
+	Type FieldType
+	// Required fields are never null. Fields are nullable by default
+	Required bool
+}
+
 // S3MetaData represents all the information we want to add to an analytics object for future reference
 // See User-Defined metadata in:
 // https://docs.aws.amazon.com/AmazonS3/latest/dev/UsingMetaData.html#object-metadata
 type S3MetaData struct {
-	SchemaName *string              `json:"x-amz-meta-schema-name"`
-	TableName  *string              `json:"x-amz-meta-table-name"`
-	FieldNames *string              `json:"x-amz-meta-field-names"`
-	FieldTypes *string              `json:"x-amz-meta-field-types"`
-	Fields     map[string]FieldType `json:"-"`
+	SchemaName *string `json:"x-amz-meta-schema-name"`
+	TableName  *string `json:"x-amz-meta-table-name"`
+	FieldNames *string `json:"x-amz-meta-field-names"`
+	FieldTypes *string `json:"x-amz-meta-field-types"`
+	// FieldNullable lists whether each field may be null, as true or false in the order of
+	// FieldNames. It is omitted when every field is nullable
+	FieldNullable *string `json:"x-amz-meta-field-nullable,omitempty"`
+	// FieldEncoding is how FieldNames are encoded, e.g. PercentEncodingV1. It is omitted when
+	// every name is written as-is
+	FieldEncoding *string `json:"x-amz-meta-field-encoding,omitempty"`
+	// FieldManifest is the key of the manifest that holds the fields when they don't fit in the
+	// metadata, and FieldManifestSHA256 is its checksum. See ConvertToS3SDKFormatWithManifest
+	FieldManifest       *string `json:"x-amz-meta-field-manifest,omitempty"`
+	FieldManifestSHA256 *string `json:"x-amz-meta-field-manifest-sha256,omitempty"`
+	// OrderedFields are the fields in the order of the columns in the data dump
+	OrderedFields []Field `json:"-"`
+	// Fields is kept in sync with OrderedFields for callers that look fields up by name. If only
+	// Fields is set, fields are written sorted by name. Fields added only to Fields are written
+	// after OrderedFields, sorted by name; to reorder or remove fields, edit OrderedFields
+	Fields map[string]FieldType `json:"-"`
 }
 
-func newS3MetaData(schema, table string, fields map[string]FieldType) *S3MetaData {
+func newS3MetaData(schema, table string, fields []Field) *S3MetaData {
 	return &S3MetaData{
-		SchemaName: &schema,
-		TableName:  &table,
-		Fields:     fields,
+		SchemaName:    &schema,
+		TableName:     &table,
+		OrderedFields: fields,
 	}
 }
 
-// GenerateS3MetaData returns a metadata object for use by the S3 sdk
+// GenerateS3MetaData returns a metadata object for use by the S3 sdk. Fields are written sorted by
+// name.
+//
+// Deprecated: use GenerateOrderedS3MetaData, which writes fields in the column order of the data
+// dump
 func GenerateS3MetaData(schema, table string, fields map[string]FieldType) (map[string]*string, error) {
+	s := &S3MetaData{SchemaName: &schema, TableName: &table, Fields: fields}
+	return s.ConvertToS3SDKFormat()
+}
+
+// GenerateOrderedS3MetaData returns a metadata object for use by the S3 sdk, with the fields in
+// exactly the order given
+func GenerateOrderedS3MetaData(schema, table string, fields []Field) (map[string]*string, error) {
 	s := newS3MetaData(schema, table, fields)
 	return s.ConvertToS3SDKFormat()
 }
 
-// NewS3MetaDataFromSDKMap returns a metadata object constructed from the S3 sdk
+// NewS3MetaDataFromSDKMap returns a metadata object constructed from the S3 sdk. Use
+// NewS3MetaDataFromSDKMapWithResolver to read metadata whose fields are in a manifest
 func NewS3MetaDataFromSDKMap(metadata map[string]*string) (*S3MetaData, error) {
+	return newS3MetaDataFromSDKMap(metadata, nil)
+}
+
+func newS3MetaDataFromSDKMap(metadata map[string]*string, resolver ManifestResolver) (*S3MetaData, error) {
 	b, err := json.Marshal(metadata)
 	if err != nil {
 		return nil, err
@@ -58,7 +109,12 @@ func NewS3MetaDataFromSDKMap(metadata map[string]*string) (*S3MetaData, error) {
 	if err := json.Unmarshal(b, &m); err != nil {
 		return nil, err
 	}
-	m.buildFields()
+	if err := m.resolveManifest(resolver); err != nil {
+		return nil, err
+	}
+	if err := m.buildFields(); err != nil {
+		return nil, err
+	}
 	if err := m.validate(); err != nil {
 		return nil, err
 	}
@@ -66,9 +122,23 @@ func NewS3MetaDataFromSDKMap(metadata map[string]*string) (*S3MetaData, error) {
 	return &m, nil
 }
 
-// ConvertToS3SDKFormat converts the S3MetaData to the map expected by the S3 sdk
+// ConvertToS3SDKFormat converts the S3MetaData to the map expected by the S3 sdk. It returns
+// ErrMetaDataTooLarge if S3 would reject the metadata
 func (m *S3MetaData) ConvertToS3SDKFormat() (map[string]*string, error) {
-	m.fieldsToStrings()
+	s, err := m.convert()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSize(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *S3MetaData) convert() (map[string]*string, error) {
+	if err := m.fieldsToStrings(); err != nil {
+		return nil, err
+	}
 	if err := m.validate(); err != nil {
 		return nil, err
 	}
@@ -84,6 +154,17 @@ func (m *S3MetaData) ConvertToS3SDKFormat() (map[string]*string, error) {
 	return s, nil
 }
 
+// RequiredFields returns the names of the fields that are never null, in field order
+func (m *S3MetaData) RequiredFields() []string {
+	required := []string{}
+	for _, f := range m.OrderedFields {
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+	return required
+}
+
 // validate determines if we have a valid metadata configuration
 func (m *S3MetaData) validate() error {
 	if m.SchemaName == nil {
@@ -99,20 +180,96 @@ func (m *S3MetaData) validate() error {
 		return fmt.Errorf(invalidFieldErrorTemplate, "field types")
 	}
 
-	if len(strings.Split(*m.FieldNames, comma)) != len(strings.Split(*m.FieldTypes, comma)) {
+	if len(strings.Split(*m.FieldNames, comma)) != len(splitFieldTypes(*m.FieldTypes)) {
 		return fmt.Errorf("field configuration mismatch. names: %s, types: %s", *m.FieldNames, *m.FieldTypes)
 	}
+	if m.FieldEncoding != nil && *m.FieldEncoding != PercentEncodingV1 {
+		return fmt.Errorf("unsupported field encoding %q", *m.FieldEncoding)
+	}
+	for _, f := range m.OrderedFields {
+		if err := validateFieldName(f.Name); err != nil {
+			return err
+		}
+		if err := f.Type.Validate(); err != nil {
+			return fmt.Errorf("field %s: %s", f.Name, err)
+		}
+	}
+	if m.FieldNullable != nil {
+		nullable := strings.Split(*m.FieldNullable, comma)
+		if len(nullable) != len(strings.Split(*m.FieldNames, comma)) {
+			return fmt.Errorf("field configuration mismatch. names: %s, nullable: %s", *m.FieldNames, *m.FieldNullable)
+		}
+		for _, n := range nullable {
+			if n != nullableTrue && n != nullableFalse {
+				return fmt.Errorf("invalid field nullability %q: must be %s or %s", n, nullableTrue, nullableFalse)
+			}
+		}
+	}
+	if m.OrderedFields != nil && len(m.OrderedFields) != len(m.Fields) {
+		return fmt.Errorf("duplicate field names: %s", *m.FieldNames)
+	}
+
+	return nil
+}
+
+// syncFields fills in whichever of OrderedFields and Fields is missing from the other. When both
+// are set, fields only in Fields are added to the end of OrderedFields, sorted by name, and a field
+// with a different type in each is an error
+func (m *S3MetaData) syncFields() error {
+	ordered := make(map[string]FieldType, len(m.OrderedFields))
+	for _, f := range m.OrderedFields {
+		ordered[f.Name] = f.Type
+	}
+	var added []string
+	for name, fieldType := range m.Fields {
+		existing, ok := ordered[name]
+		if !ok {
+			added = append(added, name)
+		} else if existing != fieldType {
+			return fmt.Errorf("field %s is %s in OrderedFields but %s in Fields", name, existing, fieldType)
+		}
+	}
+	sort.Strings(added)
 
+	merged := append([]Field{}, m.OrderedFields...)
+	for _, name := range added {
+		merged = append(merged, Field{Name: name, Type: m.Fields[name]})
+	}
+	m.OrderedFields = merged
+	m.Fields = make(map[string]FieldType, len(merged))
+	for _, f := range merged {
+		m.Fields[f.Name] = f.Type
+	}
 	return nil
 }
 
-func (m *S3MetaData) fieldsToStrings() {
+func (m *S3MetaData) fieldsToStrings() error {
+	if err := m.syncFields(); err != nil {
+		return err
+	}
+
 	var fieldNames []string
 	var fieldTypes []string
+	var fieldNullable []string
+	anyRequired := false
+	encode := false
+	for _, f := range m.OrderedFields {
+		encode = encode || needsEncoding(f.Name)
+	}
 
-	for k, v := range m.Fields {
-		fieldNames = append(fieldNames, k)
-		fieldTypes = append(fieldTypes, string(v))
+	for _, f := range m.OrderedFields {
+		name := f.Name
+		if encode {
+			name = encodeFieldName(name)
+		}
+		fieldNames = append(fieldNames, name)
+		fieldTypes = append(fieldTypes, string(f.Type))
+		if f.Required {
+			anyRequired = true
+			fieldNullable = append(fieldNullable, nullableFalse)
+		} else {
+			fieldNullable = append(fieldNullable, nullableTrue)
+		}
 	}
 
 	names := strings.Join(fieldNames, comma)
@@ -120,17 +277,56 @@ func (m *S3MetaData) fieldsToStrings() {
 
 	types := strings.Join(fieldTypes, comma)
 	m.FieldTypes = &types
+
+	// leave the header off when it says nothing, for readers that predate it
+	m.FieldNullable = nil
+	if anyRequired {
+		nullable := strings.Join(fieldNullable, comma)
+		m.FieldNullable = &nullable
+	}
+
+	// the fields are written in full, ConvertToS3SDKFormatWithManifest moves them to a manifest
+	m.FieldManifest = nil
+	m.FieldManifestSHA256 = nil
+
+	// only encode names when some need it
+	m.FieldEncoding = nil
+	if encode {
+		encoding := PercentEncodingV1
+		m.FieldEncoding = &encoding
+	}
+	return nil
 }
 
-func (m *S3MetaData) buildFields() {
+func (m *S3MetaData) buildFields() error {
 	if m.FieldNames == nil || m.FieldTypes == nil {
-		return
+		return nil
 	}
 	fieldNamesArr := strings.Split(*m.FieldNames, comma)
-	fieldTypesArr := strings.Split(*m.FieldTypes, comma)
+	fieldTypesArr := splitFieldTypes(*m.FieldTypes)
+	var fieldNullableArr []string
+	if m.FieldNullable != nil {
+		fieldNullableArr = strings.Split(*m.FieldNullable, comma)
+	}
+	m.OrderedFields = []Field{}
 	m.Fields = make(map[string]FieldType)
 
+	// validate reports names, types and nullability that don't line up
 	for idx, fieldName := range fieldNamesArr {
-		m.Fields[fieldName] = FieldType(fieldTypesArr[idx])
+		if idx >= len(fieldTypesArr) {
+			break
+		}
+		if m.FieldEncoding != nil && *m.FieldEncoding == PercentEncodingV1 {
+			var err error
+			if fieldName, err = decodeFieldName(fieldName); err != nil {
+				return err
+			}
+		}
+		field := Field{Name: fieldName, Type: FieldType(fieldTypesArr[idx])}
+		// fields are nullable unless the header says otherwise
+		field.Required = idx < len(fieldNullableArr) && fieldNullableArr[idx] == nullableFalse
+		m.OrderedFields = append(m.OrderedFields, field)
+		m.Fields[fieldName] = field.Type
 	}
+	return nil
 }
@@ -11,6 +11,8 @@ const (
 	mTable      = "x-amz-meta-table-name"
 	mFieldNames = "x-amz-meta-field-names"
 	mFieldTypes = "x-amz-meta-field-types"
+
+	mFieldNullable = "x-amz-meta-field-nullable"
 )
 
 var (
@@ -37,12 +39,50 @@ func TestNewS3MetaDataFromMap(t *testing.T) {
 				mFieldTypes: &testFieldType,
 			},
 			want: &S3MetaData{
-				SchemaName: &testSchema,
-				TableName:  &testTable,
-				FieldNames: &testFieldID,
-				FieldTypes: &testFieldType,
-				Fields:     testFields,
+				SchemaName:    &testSchema,
+				TableName:     &testTable,
+				FieldNames:    &testFieldID,
+				FieldTypes:    &testFieldType,
+				OrderedFields: []Field{{Name: testFieldID, Type: String}},
+				Fields:        testFields,
+			},
+		},
+		{
+			name: "keeps field order",
+			args: map[string]*string{
+				mSchema:     &testSchema,
+				mTable:      &testTable,
+				mFieldNames: testStrPtr("z,a,m"),
+				mFieldTypes: testStrPtr("string,integer,boolean"),
+			},
+			want: &S3MetaData{
+				SchemaName:    &testSchema,
+				TableName:     &testTable,
+				FieldNames:    testStrPtr("z,a,m"),
+				FieldTypes:    testStrPtr("string,integer,boolean"),
+				OrderedFields: []Field{{Name: "z", Type: String}, {Name: "a", Type: Integer}, {Name: "m", Type: Boolean}},
+				Fields:        map[string]FieldType{"z": String, "a": Integer, "m": Boolean},
+			},
+		},
+		{
+			name: "more names than types",
+			args: map[string]*string{
+				mSchema:     &testSchema,
+				mTable:      &testTable,
+				mFieldNames: testStrPtr("id,name"),
+				mFieldTypes: &testFieldType,
 			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate names",
+			args: map[string]*string{
+				mSchema:     &testSchema,
+				mTable:      &testTable,
+				mFieldNames: testStrPtr("id,id"),
+				mFieldTypes: testStrPtr("string,string"),
+			},
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
@@ -89,6 +129,66 @@ func TestS3MetaData_ConvertToS3SDKFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateOrderedS3MetaData(t *testing.T) {
+	fields := []Field{
+		{Name: "zeta", Type: String},
+		{Name: "alpha", Type: Integer},
+		{Name: "mu", Type: Timestamp},
+		{Name: "beta", Type: Boolean},
+	}
+	for i := 0; i < 10; i++ {
+		got, err := GenerateOrderedS3MetaData(testSchema, testTable, fields)
+		require.NoError(t, err)
+		require.Equal(t, "zeta,alpha,mu,beta", *got[mFieldNames])
+		require.Equal(t, "string,integer,timestamp,boolean", *got[mFieldTypes])
+
+		parsed, err := NewS3MetaDataFromSDKMap(got)
+		require.NoError(t, err)
+		require.Equal(t, fields, parsed.OrderedFields)
+	}
+
+	_, err := GenerateOrderedS3MetaData(testSchema, testTable, []Field{{Name: "id", Type: String}, {Name: "id", Type: Integer}})
+	require.EqualError(t, err, "duplicate field names: id,id")
+}
+
+func TestGenerateS3MetaDataSortsFields(t *testing.T) {
+	fields := map[string]FieldType{"zeta": String, "alpha": Integer, "mu": Timestamp, "beta": Boolean}
+	for i := 0; i < 10; i++ {
+		got, err := GenerateS3MetaData(testSchema, testTable, fields)
+		require.NoError(t, err)
+		require.Equal(t, "alpha,beta,mu,zeta", *got[mFieldNames])
+		require.Equal(t, "integer,boolean,timestamp,string", *got[mFieldTypes])
+	}
+}
+
+func TestFieldsEditsAreKept(t *testing.T) {
+	m := &S3MetaData{
+		SchemaName:    &testSchema,
+		TableName:     &testTable,
+		OrderedFields: []Field{{Name: "zeta", Type: String}, {Name: "alpha", Type: Integer}},
+		Fields:        map[string]FieldType{"zeta": String, "alpha": Integer, "extra": Boolean, "another": Date},
+	}
+	got, err := m.ConvertToS3SDKFormat()
+	require.NoError(t, err)
+	require.Equal(t, "zeta,alpha,another,extra", *got[mFieldNames])
+	require.Equal(t, "string,integer,date,boolean", *got[mFieldTypes])
+
+	// the baseline flow: read metadata, add a field by name and write it back
+	parsed, err := NewS3MetaDataFromSDKMap(got)
+	require.NoError(t, err)
+	parsed.Fields["new"] = UUID
+	got, err = parsed.ConvertToS3SDKFormat()
+	require.NoError(t, err)
+	require.Equal(t, "zeta,alpha,another,extra,new", *got[mFieldNames])
+	require.Equal(t, Field{Name: "new", Type: UUID}, parsed.OrderedFields[4])
+
+	// a field can't have two types
+	parsed.Fields["zeta"] = Integer
+	_, err = parsed.ConvertToS3SDKFormat()
+	require.EqualError(t, err, "field zeta is string in OrderedFields but integer in Fields")
+}
+
 func TestS3MetaData_validate(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -158,3 +258,55 @@ func TestS3MetaData_validate(t *testing.T) {
 func testStrPtr(s string) *string {
 	return &s
 }
+
+func TestFieldNullability(t *testing.T) {
+	fields := []Field{
+		{Name: "id", Type: UUID, Required: true},
+		{Name: "name", Type: String},
+		{Name: "created", Type: Timestamp, Required: true},
+	}
+	m, err := GenerateOrderedS3MetaData(testSchema, testTable, fields)
+	require.NoError(t, err)
+	require.Equal(t, "false,true,false", *m[mFieldNullable])
+
+	parsed, err := NewS3MetaDataFromSDKMap(m)
+	require.NoError(t, err)
+	require.Equal(t, fields, parsed.OrderedFields)
+	require.Equal(t, []string{"id", "created"}, parsed.RequiredFields())
+
+	// the header is left off when every field is nullable
+	m, err = GenerateOrderedS3MetaData(testSchema, testTable, []Field{{Name: "id", Type: UUID}})
+	require.NoError(t, err)
+	require.NotContains(t, m, mFieldNullable)
+
+	// and fields default to nullable when it is absent
+	parsed, err = NewS3MetaDataFromSDKMap(m)
+	require.NoError(t, err)
+	require.False(t, parsed.OrderedFields[0].Required)
+	require.Empty(t, parsed.RequiredFields())
+}
+
+func TestFieldNullabilityInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		nullable string
+		wantErr  string
+	}{
+		{name: "too few", nullable: "false", wantErr: "field configuration mismatch. names: id,name, nullable: false"},
+		{name: "too many", nullable: "false,true,true", wantErr: "mismatch"},
+		{name: "not a bool", nullable: "false,yes", wantErr: `invalid field nullability "yes"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewS3MetaDataFromSDKMap(map[string]*string{
+				mSchema:        &testSchema,
+				mTable:         &testTable,
+				mFieldNames:    testStrPtr("id,name"),
+				mFieldTypes:    testStrPtr("uuid,string"),
+				mFieldNullable: &tt.nullable,
+			})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
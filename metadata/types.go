@@ -0,0 +1,125 @@
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxDecimalPrecision = 38
+	maxVarcharLength    = 65535
+)
+
+// DecimalType returns a decimal type with the given precision and scale, e.g. decimal(12,2)
+func DecimalType(precision, scale int) FieldType {
+	return FieldType(fmt.Sprintf("%s(%d,%d)", Decimal, precision, scale))
+}
+
+// VarcharType returns a varchar type with the given maximum length, e.g. varchar(256)
+func VarcharType(length int) FieldType {
+	return FieldType(fmt.Sprintf("%s(%d)", Varchar, length))
+}
+
+// ArrayType returns the type of an array of elem, e.g. array(integer)
+func ArrayType(elem FieldType) FieldType {
+	return FieldType(fmt.Sprintf("%s(%s)", Array, elem))
+}
+
+// Base returns the type without its parameters, e.g. decimal for decimal(12,2)
+func (t FieldType) Base() FieldType {
+	base, _, _ := t.split()
+	return base
+}
+
+// split separates a type into its base and parameters, and reports whether it had parentheses
+func (t FieldType) split() (FieldType, string, bool) {
+	s := string(t)
+	open := strings.Index(s, "(")
+	if open < 0 {
+		return t, "", false
+	}
+	return FieldType(s[:open]), strings.TrimSuffix(s[open+1:], ")"), true
+}
+
+// Validate returns an error if the type is not supported, or its parameters are invalid for it
+func (t FieldType) Validate() error {
+	s := string(t)
+	base, params, hasParams := t.split()
+	if hasParams && !strings.HasSuffix(s, ")") {
+		return fmt.Errorf("invalid field type %q: unbalanced parentheses", s)
+	}
+
+	switch base {
+	case Boolean, Integer, MongoID, String, Timestamp, BigInt, Float, Date, JSON, UUID:
+		if hasParams {
+			return fmt.Errorf("invalid field type %q: %s takes no parameters", s, base)
+		}
+		return nil
+	case Decimal:
+		if !hasParams {
+			return nil
+		}
+		return validateDecimal(s, params)
+	case Varchar:
+		if !hasParams {
+			return nil
+		}
+		length, err := strconv.Atoi(params)
+		if err != nil || length < 1 || length > maxVarcharLength {
+			return fmt.Errorf("invalid field type %q: length must be between 1 and %d", s, maxVarcharLength)
+		}
+		return nil
+	case Array:
+		if !hasParams {
+			return nil
+		}
+		if err := FieldType(params).Validate(); err != nil {
+			return fmt.Errorf("invalid field type %q: %s", s, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown field type %q", s)
+	}
+}
+
+func validateDecimal(s, params string) error {
+	parts := strings.Split(params, comma)
+	if len(parts) > 2 {
+		return fmt.Errorf("invalid field type %q: expected decimal(precision) or decimal(precision,scale)", s)
+	}
+	precision, err := strconv.Atoi(parts[0])
+	if err != nil || precision < 1 || precision > maxDecimalPrecision {
+		return fmt.Errorf("invalid field type %q: precision must be between 1 and %d", s, maxDecimalPrecision)
+	}
+	if len(parts) == 2 {
+		scale, err := strconv.Atoi(parts[1])
+		if err != nil || scale < 0 || scale > precision {
+			return fmt.Errorf("invalid field type %q: scale must be between 0 and the precision", s)
+		}
+	}
+	return nil
+}
+
+// splitFieldTypes splits a comma separated list of field types, ignoring commas inside the
+// parameters of a type like decimal(12,2)
+func splitFieldTypes(s string) []string {
+	var types []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				types = append(types, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(types, s[start:])
+}
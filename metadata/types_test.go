@@ -0,0 +1,87 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldType_Validate(t *testing.T) {
+	tests := []struct {
+		fieldType FieldType
+		wantErr   string
+	}{
+		{fieldType: Boolean},
+		{fieldType: MongoID},
+		{fieldType: BigInt},
+		{fieldType: Float},
+		{fieldType: Date},
+		{fieldType: JSON},
+		{fieldType: UUID},
+		{fieldType: Decimal},
+		{fieldType: DecimalType(12, 2)},
+		{fieldType: "decimal(38)"},
+		{fieldType: Varchar},
+		{fieldType: VarcharType(256)},
+		{fieldType: Array},
+		{fieldType: ArrayType(Integer)},
+		{fieldType: ArrayType(DecimalType(12, 2))},
+		{fieldType: "double", wantErr: `unknown field type "double"`},
+		{fieldType: "", wantErr: `unknown field type ""`},
+		{fieldType: "string(10)", wantErr: "string takes no parameters"},
+		{fieldType: "decimal(39,2)", wantErr: "precision must be between 1 and 38"},
+		{fieldType: "decimal(12,13)", wantErr: "scale must be between 0 and the precision"},
+		{fieldType: "decimal(12,2,1)", wantErr: "expected decimal(precision) or decimal(precision,scale)"},
+		{fieldType: "decimal(a)", wantErr: "precision must be"},
+		{fieldType: "varchar(0)", wantErr: "length must be between 1 and 65535"},
+		{fieldType: "varchar(256", wantErr: "unbalanced parentheses"},
+		{fieldType: "array(double)", wantErr: `unknown field type "double"`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.fieldType), func(t *testing.T) {
+			err := tt.fieldType.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestFieldType_Base(t *testing.T) {
+	require.Equal(t, Decimal, DecimalType(12, 2).Base())
+	require.Equal(t, Varchar, VarcharType(256).Base())
+	require.Equal(t, Array, ArrayType(Integer).Base())
+	require.Equal(t, String, String.Base())
+}
+
+func TestSplitFieldTypes(t *testing.T) {
+	require.Equal(t, []string{"string"}, splitFieldTypes("string"))
+	require.Equal(t, []string{"decimal(12,2)", "varchar(256)", "array(decimal(4,1))", "integer"},
+		splitFieldTypes("decimal(12,2),varchar(256),array(decimal(4,1)),integer"))
+}
+
+func TestParameterizedTypesRoundTrip(t *testing.T) {
+	fields := []Field{
+		{Name: "price", Type: DecimalType(12, 2)},
+		{Name: "name", Type: VarcharType(256)},
+		{Name: "id", Type: UUID},
+		{Name: "scores", Type: ArrayType(Float)},
+	}
+	m, err := GenerateOrderedS3MetaData(testSchema, testTable, fields)
+	require.NoError(t, err)
+	require.Equal(t, "decimal(12,2),varchar(256),uuid,array(float)", *m[mFieldTypes])
+
+	parsed, err := NewS3MetaDataFromSDKMap(m)
+	require.NoError(t, err)
+	require.Equal(t, fields, parsed.OrderedFields)
+
+	m[mFieldTypes] = testStrPtr("decimal(12,2),varchar(256),uuid,double")
+	_, err = NewS3MetaDataFromSDKMap(m)
+	require.EqualError(t, err, `field scores: unknown field type "double"`)
+
+	_, err = GenerateOrderedS3MetaData(testSchema, testTable, []Field{{Name: "id", Type: "objectid"}})
+	require.EqualError(t, err, `field id: unknown field type "objectid"`)
+}